@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// doctorReport lists the problems doctor found in the library.
+type doctorReport struct {
+	// MissingPaths are the records whose path doesn't exist anymore.
+	MissingPaths []db.Record
+	// UnknownFolders are the media folders under the library root with neither a record nor an item in the review queue.
+	UnknownFolders []mediaFolder
+	BadPosters     []db.PosterProblem
+	DuplicatePaths []db.Record
+	DuplicateIDs   []db.Record
+}
+
+func (r doctorReport) count() int {
+	return len(r.MissingPaths) + len(r.UnknownFolders) + len(r.BadPosters) + len(r.DuplicatePaths) + len(r.DuplicateIDs)
+}
+
+var errEmptyPoster = errors.New("empty poster")
+
+// checkPoster fails on an empty poster, or one that doesn't decode as an image.
+func checkPoster(poster []byte) error {
+	if len(poster) == 0 {
+		return errEmptyPoster
+	}
+	if _, _, err := image.Decode(bytes.NewReader(poster)); err != nil {
+		return fmt.Errorf("undecodable poster: %w", err)
+	}
+	return nil
+}
+
+// checkLibrary looks up dbh for the problems of doctorReport. Folders are looked for under root, an absolute path.
+func checkLibrary(dbh *db.DBHandler, root string) (report doctorReport, err error) {
+	records, err := dbh.ListMedia(db.MediaFilter{Sort: "path"})
+	if err != nil {
+		return report, fmt.Errorf("listing media: %w", err)
+	}
+	known := make(map[string]bool)
+	for _, r := range records {
+		known[r.Path] = true
+		if _, err := os.Stat(r.Path); errors.Is(err, fs.ErrNotExist) {
+			report.MissingPaths = append(report.MissingPaths, r)
+		} else if err != nil {
+			log.Printf(" Couldn't read %v: %v\n", r.Path, err)
+		}
+	}
+	queue, err := dbh.ReviewQueue()
+	if err != nil {
+		return report, fmt.Errorf("reading review queue: %w", err)
+	}
+	for _, item := range queue {
+		known[item.Path] = true
+	}
+	folders, err := findMediaFolders(root)
+	if err != nil {
+		return report, fmt.Errorf("walking library root: %w", err)
+	}
+	for _, f := range folders {
+		if !known[f.Path] {
+			report.UnknownFolders = append(report.UnknownFolders, f)
+		}
+	}
+	if report.BadPosters, err = dbh.CheckPosters(checkPoster); err != nil {
+		return report, fmt.Errorf("checking posters: %w", err)
+	}
+	if report.DuplicatePaths, err = dbh.DuplicatePaths(); err != nil {
+		return report, fmt.Errorf("looking for duplicate paths: %w", err)
+	}
+	if report.DuplicateIDs, err = dbh.DuplicateIDs(); err != nil {
+		return report, fmt.Errorf("looking for duplicate ids: %w", err)
+	}
+	return report, nil
+}
+
+// writeReport prints report to w, a section per kind of problem.
+func writeReport(w io.Writer, report doctorReport) {
+	if report.count() == 0 {
+		fmt.Fprintln(w, "✓ Found no problem.")
+		return
+	}
+	section := func(title string, n int) {
+		if n > 0 {
+			fmt.Fprintf(w, "%v (%v):\n", title, n)
+		}
+	}
+	section("Missing paths", len(report.MissingPaths))
+	for _, r := range report.MissingPaths {
+		fmt.Fprintf(w, "  %v\t%v/%v «%v» (%v)\n", r.Path, r.MediaType, r.ID, r.GetTitle(), r.GetYear())
+	}
+	section("Folders not in the database", len(report.UnknownFolders))
+	for _, f := range report.UnknownFolders {
+		fmt.Fprintf(w, "  %v\n", f.Path)
+	}
+	section("Bad posters", len(report.BadPosters))
+	for _, p := range report.BadPosters {
+		fmt.Fprintf(w, "  %v\t%v/%v «%v» (%v): %v\n", p.Path, p.MediaType, p.ID, p.GetTitle(), p.GetYear(), p.Err)
+	}
+	section("Duplicate paths", len(report.DuplicatePaths))
+	for _, r := range report.DuplicatePaths {
+		fmt.Fprintf(w, "  %v\t%v/%v «%v» (%v)\n", r.Path, r.MediaType, r.ID, r.GetTitle(), r.GetYear())
+	}
+	section("Duplicate ids", len(report.DuplicateIDs))
+	for _, r := range report.DuplicateIDs {
+		fmt.Fprintf(w, "  %v/%v «%v» (%v)\t%v\n", r.MediaType, r.ID, r.GetTitle(), r.GetYear(), r.Path)
+	}
+}
+
+// isUnder reports whether path is root or in it, both being absolute.
+func isUnder(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fixLibrary fixes the problems of report under root: records with a missing path are deleted,
+// folders not in the database are scanned, and records with a bad poster are fetched again by id.
+// Duplicates are left alone, they need a human decision.
+func (s *scanner) fixLibrary(ctx context.Context, root string, report doctorReport) (pruned []string, summary scanSummary, err error) {
+	missing := make(map[string]bool)
+	for _, r := range report.MissingPaths {
+		missing[r.Path] = true
+		if !isUnder(root, r.Path) {
+			continue
+		}
+		if err := s.dbh.DeleteMedia(r.ID, r.Path); err != nil {
+			return pruned, summary, fmt.Errorf("deleting %v: %w", r.Path, err)
+		}
+		s.printf("✓ Deleted %v from DB: %v\n", r.Path, r.Media)
+		pruned = append(pruned, r.Path)
+	}
+	folders := report.UnknownFolders
+	for _, p := range report.BadPosters {
+		if missing[p.Path] || !isUnder(root, p.Path) {
+			continue
+		}
+		// Without a title, the scan doesn't stop at the record already in DB.
+		f := mediaFolder{Path: p.Path, TMDBID: p.ID, TMDBType: p.MediaType}
+		// Media found on OMDb have no TMDB id, see api.IMDbMediaID.
+		if p.ID < 0 {
+			f = mediaFolder{Path: p.Path, IMDbID: p.IMDbID}
+		}
+		folders = append(folders, f)
+	}
+	if len(folders) > 0 {
+		summary = s.scanFolders(ctx, folders)
+	}
+	return pruned, summary, nil
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [root]",
+	Short: "Checks the database against the library folders",
+	Long: `Reports the media whose path doesn't exist anymore, the media folders under root (default: current folder) missing from the database,
+the media with an empty or undecodable poster, and the paths or ids shared by several media.
+With --fix, under root only: media with a missing path are deleted, folders missing from the database are scanned like scan --recursive --auto does,
+and media with a bad poster are fetched again by id. Duplicates are only reported, pick the right media by hand.
+Exits with status 1 if problems are left.`,
+	Example: `  mymedia doctor /mnt/films
+  mymedia doctor --fix /mnt/films`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, err := cmd.Flags().GetBool("fix")
+		if err != nil {
+			log.Fatalln(" Couldn't read fix flag from config")
+		}
+		root := "."
+		if len(args) == 1 {
+			root = args[0]
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			log.Fatalln(" Couldn't resolve library root: ", err)
+		}
+		report, err := checkLibrary(localConfig.DBH, root)
+		if err != nil {
+			log.Fatalln(" Couldn't check library: ", err)
+		}
+		writeReport(os.Stdout, report)
+		if report.count() == 0 {
+			return
+		}
+		if !fix {
+			os.Exit(1)
+		}
+		s := &scanner{
+			providers: newProviders(),
+			dbh:       localConfig.DBH,
+			tolerance: parseTolerance(cmd),
+			policy:    acceptPolicy{mode: acceptAuto},
+			jobs:      1,
+			out:       log.New(os.Stdout, "", 0),
+		}
+		pruned, summary, err := s.fixLibrary(cmd.Context(), root, report)
+		if err != nil {
+			log.Fatalln(" Couldn't fix library: ", err)
+		}
+		fmt.Printf("Deleted %v media with a missing path\n", len(pruned))
+		fmt.Print(summary)
+		if report, err = checkLibrary(localConfig.DBH, root); err != nil {
+			log.Fatalln(" Couldn't check library: ", err)
+		}
+		if report.count() > 0 {
+			fmt.Println("Left:")
+			writeReport(os.Stdout, report)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().Bool("fix", false, "delete the media with a missing path, scan the folders missing from the database and fetch bad posters again")
+	doctorCmd.Flags().Int("tolerance", 2, "with --fix, on lookup, result will be accepted if title match and year is within tolerance of result")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestDoctor(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	root := t.TempDir()
+	for _, dir := range []string{"Alien (1979)", "Heat (1995)", "Nothing matches this (2000)", "Queued (2001)"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", PosterData: []byte("not a jpeg")}
+	gone := api.Media{ID: 679, MediaType: api.MediaTypeMovie, Title: "Aliens", ReleaseDate: "1986-07-18"}
+	elsewhere := api.Media{ID: 194, MediaType: api.MediaTypeMovie, Title: "Amélie", ReleaseDate: "2001-04-25"}
+	for path, m := range map[string]api.Media{
+		filepath.Join(root, "Alien (1979)"):  alien,
+		filepath.Join(root, "Aliens (1986)"): gone,
+		"/elsewhere/Amélie (2001)":           elsewhere,
+	} {
+		if _, err := dbh.WriteToDB(m, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dbh.QueueForReview(filepath.Join(root, "Queued (2001)"), "Queued", 2001, api.Media{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := checkLibrary(dbh, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing, unknown, badPosters []string
+	for _, r := range report.MissingPaths {
+		missing = append(missing, r.Path)
+	}
+	for _, f := range report.UnknownFolders {
+		unknown = append(unknown, f.Path)
+	}
+	for _, p := range report.BadPosters {
+		badPosters = append(badPosters, p.Path)
+	}
+	if want := []string{"/elsewhere/Amélie (2001)", filepath.Join(root, "Aliens (1986)")}; !slices.Equal(missing, want) {
+		t.Errorf("got missing paths %v, want %v", missing, want)
+	}
+	if want := []string{filepath.Join(root, "Heat (1995)"), filepath.Join(root, "Nothing matches this (2000)")}; !slices.Equal(unknown, want) {
+		t.Errorf("got unknown folders %v, want %v", unknown, want)
+	}
+	// All three have bad posters.
+	if len(badPosters) != 3 || len(report.DuplicatePaths) != 0 || len(report.DuplicateIDs) != 0 {
+		t.Errorf("got bad posters %v and duplicates %+v %+v, want 3 bad posters and no duplicate", badPosters, report.DuplicatePaths, report.DuplicateIDs)
+	}
+
+	pruned, summary, err := s.fixLibrary(context.Background(), root, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(root, "Aliens (1986)")}; !slices.Equal(pruned, want) {
+		t.Errorf("pruned %v, want %v", pruned, want)
+	}
+	if want := []string{filepath.Join(root, "Heat (1995)"), filepath.Join(root, "Alien (1979)")}; !slices.Equal(summary.Matched, want) {
+		t.Errorf("matched %v, want %v", summary.Matched, want)
+	}
+	if report, err = checkLibrary(dbh, root); err != nil {
+		t.Fatal(err)
+	}
+	// Amélie is outside root, Nothing matches this is queued for review.
+	if len(report.MissingPaths) != 1 || len(report.UnknownFolders) != 0 || len(report.BadPosters) != 1 {
+		t.Errorf("left %+v, want Amélie", report)
+	}
+	if want := []string{filepath.Join(root, "Nothing matches this (2000)")}; !slices.Equal(summary.Queued, want) {
+		t.Errorf("queued %v, want %v", summary.Queued, want)
+	}
+	rows := readMediaRows(t, dbh)
+	var ids []int
+	for _, row := range rows {
+		ids = append(ids, row.id)
+		if row.id == alien.ID && checkPoster(row.poster) != nil {
+			t.Errorf("%v still has a bad poster", row.title)
+		}
+	}
+	if want := []int{194, 348, 949}; !slices.Equal(ids, want) {
+		t.Errorf("got ids %v, want %v", ids, want)
+	}
+}
+
+func TestIsUnder(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/films", true},
+		{"/films/Alien (1979)", true},
+		{"/films/A/Alien (1979)", true},
+		{"/films2/Alien (1979)", false},
+		{"/", false},
+		{"/shows/..films", false},
+		{"/films/..Alien", true},
+	}
+	for _, tt := range tests {
+		if got := isUnder("/films", tt.path); got != tt.want {
+			t.Errorf("isUnder(/films, %v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+)
+
+// planAction is what a scan would do with a folder.
+type planAction string
+
+const (
+	planInsert planAction = "insert"
+	planUpdate planAction = "update"
+	// planKeep leaves the DB alone: the folder is skipped or its match is already written as is.
+	planKeep   planAction = "keep"
+	planReview planAction = "review"
+	planError  planAction = "error"
+)
+
+var planActionIcons = map[planAction]string{
+	planInsert: "+",
+	planUpdate: "~",
+	planKeep:   "=",
+	planReview: "?",
+	planError:  "",
+}
+
+// fieldChange is a field of a DB record that a scan would change.
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// planMedia identifies the media of a scan plan.
+type planMedia struct {
+	ID        int    `json:"id"`
+	MediaType string `json:"media_type"`
+	Title     string `json:"title"`
+	Year      int    `json:"year"`
+	Url       string `json:"url"`
+}
+
+func newPlanMedia(m api.Media) *planMedia {
+	return &planMedia{ID: m.ID, MediaType: m.MediaType, Title: m.GetTitle(), Year: m.GetYear(), Url: m.Url()}
+}
+
+func (m planMedia) String() string {
+	return fmt.Sprintf("%v «%v» (%v) @ %v", api.MediaTypeIcons[m.MediaType], m.Title, m.Year, m.Url)
+}
+
+// scanPlan is what a scan would do with a folder, and why.
+type scanPlan struct {
+	Path   string     `json:"path"`
+	Action planAction `json:"action"`
+	Media  *planMedia `json:"media,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	// Changes lists the fields an update would change, compared to the record checkDB found.
+	Changes []fieldChange `json:"changes,omitempty"`
+}
+
+// plan returns the plan of r. Matches are written over the record with their id,
+// so they're compared to that record rather than the one checkDB found by title.
+func (s *scanner) plan(r scanResult) scanPlan {
+	if r.status == scanMatched {
+		record, found, err := s.dbh.FindMediaByID(r.media.ID)
+		switch {
+		case err != nil:
+			r.status, r.err = scanFailed, fmt.Errorf("DB query error: %w", err)
+		case found:
+			r.existing = &record
+		default:
+			r.existing = nil
+		}
+	}
+	return newScanPlan(r)
+}
+
+func newScanPlan(r scanResult) scanPlan {
+	p := scanPlan{Path: r.folder.Path, Reason: r.reason}
+	switch r.status {
+	case scanFailed:
+		p.Action, p.Error = planError, r.err.Error()
+	case scanQueued:
+		p.Action, p.Media = planReview, newPlanMedia(r.media)
+	case scanSkipped:
+		p.Action = planKeep
+		if r.existing != nil {
+			p.Media = newPlanMedia(r.existing.Media)
+		}
+	case scanMatched:
+		p.Media = newPlanMedia(r.media)
+		if r.existing == nil {
+			p.Action = planInsert
+			break
+		}
+		p.Changes = diffRecords(*r.existing, db.Record{Media: r.media, Path: r.folder.Path})
+		if len(p.Changes) == 0 {
+			p.Action, p.Reason = planKeep, "no changes"
+		} else {
+			p.Action = planUpdate
+		}
+	}
+	return p
+}
+
+// diffRecords lists the fields that differ from old to new.
+// Posters are compared by hash.
+func diffRecords(old, new db.Record) []fieldChange {
+	var changes []fieldChange
+	for _, f := range []fieldChange{
+		{"title", old.GetTitle(), new.GetTitle()},
+		{"year", strconv.Itoa(old.GetYear()), strconv.Itoa(new.GetYear())},
+		{"release_date", old.GetReleaseDate(), new.GetReleaseDate()},
+		{"overview", old.Overview, new.Overview},
+		{"original_overview", old.OriginalOverview, new.OriginalOverview},
+		{"tagline", old.Tagline, new.Tagline},
+		{"genres", strings.Join(old.GenreNames(), ", "), strings.Join(sortedGenreNames(new.Media), ", ")},
+		{"runtime", strconv.Itoa(old.GetRuntime()), strconv.Itoa(new.GetRuntime())},
+		{"vote_average", strconv.FormatFloat(old.VoteAverage, 'f', -1, 64), strconv.FormatFloat(new.VoteAverage, 'f', -1, 64)},
+		{"original_title", old.GetOriginalTitle(), new.GetOriginalTitle()},
+		{"original_language", old.OriginalLanguage, new.OriginalLanguage},
+		{"imdb_id", old.IMDbID, new.IMDbID},
+		{"director", old.Director, new.Director},
+		{"path", old.Path, new.Path},
+		{"poster", posterHash(old.PosterData), posterHash(new.PosterData)},
+	} {
+		if f.Old != f.New {
+			changes = append(changes, f)
+		}
+	}
+	return changes
+}
+
+// sortedGenreNames lists the genre names of m by name, the order they're read from the DB in.
+func sortedGenreNames(m api.Media) []string {
+	names := m.GenreNames()
+	slices.Sort(names)
+	return names
+}
+
+// posterHash returns a short sha256 of poster, or an empty string if there's no poster.
+func posterHash(poster []byte) string {
+	if len(poster) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(poster)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// shorten cuts s to n runes, marking the cut with an ellipsis.
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// writePlansText prints plans one folder per line, with the changes of updates indented under them,
+// followed by a count of plans by action.
+func writePlansText(w io.Writer, plans []scanPlan) {
+	counts := make(map[planAction]int)
+	for _, p := range plans {
+		counts[p.Action]++
+		fmt.Fprintf(w, "%v %-6v %v", planActionIcons[p.Action], p.Action, p.Path)
+		if p.Media != nil {
+			fmt.Fprintf(w, " → %v", p.Media)
+		}
+		if p.Reason != "" {
+			fmt.Fprintf(w, " (%v)", p.Reason)
+		}
+		if p.Error != "" {
+			fmt.Fprintf(w, ": %v", p.Error)
+		}
+		fmt.Fprintln(w)
+		for _, c := range p.Changes {
+			fmt.Fprintf(w, "    %-8v %q → %q\n", c.Field, shorten(c.Old, 60), shorten(c.New, 60))
+		}
+	}
+	fmt.Fprintf(w, "Dry run, nothing was written: %v to insert, %v to update, %v left alone, %v to review, %v failed\n",
+		counts[planInsert], counts[planUpdate], counts[planKeep], counts[planReview], counts[planError])
+}
+
+// writePlansJSON prints plans as an indented JSON array.
+func writePlansJSON(w io.Writer, plans []scanPlan) error {
+	if plans == nil {
+		plans = []scanPlan{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plans)
+}
+
+// readOnlyCache reads responses from a cache without storing new ones, so that dry runs don't write to the DB.
+type readOnlyCache struct {
+	api.Cache
+}
+
+func (readOnlyCache) Set(key string, data []byte) error { return nil }
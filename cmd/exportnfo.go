@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/nfo"
+	"github.com/spf13/cobra"
+)
+
+// writeFileUnlessExists writes data to the file at path, unless it exists and replace is false.
+// Reports whether it wrote the file.
+func writeFileUnlessExists(path string, data []byte, replace bool) (bool, error) {
+	if _, err := os.Lstat(path); err == nil && !replace {
+		return false, nil
+	}
+	return true, os.WriteFile(path, data, 0o644)
+}
+
+// exportNFO writes the .nfo file of r, with its credits, its poster, backdrop and logo in its folder, see package nfo.
+// Existing files are kept unless replace is true. Returns the names of the files written.
+func exportNFO(dbh *db.DBHandler, r db.Record, replace bool) (written []string, err error) {
+	if r.Credits, err = dbh.Credits(r.ID); err != nil {
+		return nil, fmt.Errorf("reading credits: %w", err)
+	}
+	var b bytes.Buffer
+	if err := nfo.Write(&b, nfo.New(r.Media)); err != nil {
+		return nil, err
+	}
+	name := nfo.FileName(r.MediaType)
+	if ok, err := writeFileUnlessExists(filepath.Join(r.Path, name), b.Bytes(), replace); err != nil {
+		return written, err
+	} else if ok {
+		written = append(written, name)
+	}
+	poster, err := dbh.Poster(r.ID)
+	if err != nil {
+		return written, fmt.Errorf("reading poster: %w", err)
+	}
+	if len(poster) > 0 {
+		if ok, err := writeFileUnlessExists(filepath.Join(r.Path, nfo.PosterFile), poster, replace); err != nil {
+			return written, err
+		} else if ok {
+			written = append(written, nfo.PosterFile)
+		}
+	}
+	for _, kind := range []string{api.ArtworkBackdrop, api.ArtworkLogo} {
+		a, found, err := dbh.Artwork(r.ID, kind)
+		if err != nil {
+			return written, fmt.Errorf("reading %v: %w", kind, err)
+		}
+		name := nfo.ArtworkFileName(a)
+		if !found || name == "" {
+			continue
+		}
+		if ok, err := writeFileUnlessExists(filepath.Join(r.Path, name), a.Data, replace); err != nil {
+			return written, err
+		} else if ok {
+			written = append(written, name)
+		}
+	}
+	return written, nil
+}
+
+// exportNFOCmd represents the export-nfo command
+var exportNFOCmd = &cobra.Command{
+	Use:   "export-nfo [root]",
+	Short: "Writes NFO files and posters for Kodi and Jellyfin",
+	Long: `Writes a movie.nfo or tvshow.nfo file, and the poster as poster.jpg, in the folder of every media of the database, or of those under root.
+The backdrop and logo downloaded by scan are written as fanart.jpg and clearlogo.png.
+The NFO files hold the title, year, plot, tagline, runtime, rating, TMDB and IMDb ids, genres, directors, writers and cast.
+Existing files are kept, unless --replace is set.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		replace, err := cmd.Flags().GetBool("replace")
+		if err != nil {
+			log.Fatalln(" Couldn't read replace flag from config")
+		}
+		root := ""
+		if len(args) == 1 {
+			if root, err = filepath.Abs(args[0]); err != nil {
+				log.Fatalln(" Couldn't resolve library root: ", err)
+			}
+		}
+		records, err := localConfig.DBH.ListMedia(db.MediaFilter{Sort: "path"})
+		if err != nil {
+			log.Fatalln(" Couldn't list media: ", err)
+		}
+		var exported, failed int
+		for _, r := range records {
+			if root != "" && !isUnder(root, r.Path) {
+				continue
+			}
+			if info, err := os.Stat(r.Path); err != nil || !info.IsDir() {
+				fmt.Printf("∅ Skipped %v: folder not found\n", r.Path)
+				continue
+			}
+			written, err := exportNFO(localConfig.DBH, r, replace)
+			if err != nil {
+				log.Printf(" Couldn't export %v: %v\n", r.Path, err)
+				failed++
+				continue
+			}
+			if len(written) > 0 {
+				fmt.Printf("✓ Wrote %v in %v\n", strings.Join(written, ", "), r.Path)
+				exported++
+			}
+		}
+		fmt.Printf("Exported %v media, %v failed\n", exported, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportNFOCmd)
+
+	exportNFOCmd.Flags().Bool("replace", false, "replace existing NFO files and images")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+)
+
+func TestExportNFO(t *testing.T) {
+	_, dbh := newTestScanner(t, acceptAuto)
+	dir := filepath.Join(t.TempDir(), "Alien (1979)")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", Director: "Ridley Scott",
+		PosterData: []byte("poster"), IMDbID: "tt0078748", Artwork: []api.Artwork{
+			{Kind: api.ArtworkBackdrop, Size: "w1280", Mime: "image/jpeg", Data: []byte("backdrop")},
+			{Kind: api.ArtworkLogo, Size: "w500", Mime: "image/png", Data: []byte("logo")},
+		}}
+	if _, err := dbh.WriteToDB(alien, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.WriteCredits(alien.ID, []api.Credit{{PersonID: 10205, Name: "Sigourney Weaver", Role: api.RoleCast, Character: "Ellen Ripley"}}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := dbh.ListMedia(db.MediaFilter{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got %+v (error %v), want Alien", records, err)
+	}
+
+	written, err := exportNFO(dbh, records[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"movie.nfo", "poster.jpg", "fanart.jpg", "clearlogo.png"}; !slices.Equal(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "movie.nfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<title>Alien</title>", `<uniqueid type="imdb">tt0078748</uniqueid>`, "<director>Ridley Scott</director>", "<name>Sigourney Weaver</name>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("movie.nfo lacks %v:\n%s", want, data)
+		}
+	}
+	for name, want := range map[string]string{"poster.jpg": "poster", "fanart.jpg": "backdrop", "clearlogo.png": "logo"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("got %v %q (error %v), want %q", name, data, err, want)
+		}
+	}
+
+	// Existing files are kept, unless replaced.
+	if written, err := exportNFO(dbh, records[0], false); err != nil || len(written) != 0 {
+		t.Errorf("wrote %v (error %v), want nothing", written, err)
+	}
+	if written, err := exportNFO(dbh, records[0], true); err != nil || len(written) != 4 {
+		t.Errorf("wrote %v (error %v), want all the files", written, err)
+	}
+}
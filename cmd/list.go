@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/medianame"
+	"github.com/spf13/cobra"
+)
+
+// listRow is a media record as printed by list.
+// Its fields are the keys of the JSON output, the columns of the CSV output and the fields of templates.
+type listRow struct {
+	ID               int      `json:"id"`
+	MediaType        string   `json:"media_type"`
+	Title            string   `json:"title"`
+	Year             int      `json:"year"`
+	ReleaseDate      string   `json:"release_date"`
+	Director         string   `json:"director"`
+	Genres           []string `json:"genres"`
+	Runtime          int      `json:"runtime"`
+	VoteAverage      float64  `json:"vote_average"`
+	Tagline          string   `json:"tagline"`
+	OriginalTitle    string   `json:"original_title"`
+	OriginalLanguage string   `json:"original_language"`
+	IMDbID           string   `json:"imdb_id"`
+	Overview         string   `json:"overview"`
+	Path             string   `json:"path"`
+	HasPoster        bool     `json:"has_poster"`
+	Url              string   `json:"url"`
+}
+
+func newListRow(r db.Record) listRow {
+	genres := r.GenreNames()
+	if genres == nil {
+		genres = []string{}
+	}
+	return listRow{
+		ID:               r.ID,
+		MediaType:        r.MediaType,
+		Title:            r.GetTitle(),
+		Year:             r.GetYear(),
+		ReleaseDate:      r.GetReleaseDate(),
+		Director:         r.Director,
+		Genres:           genres,
+		Runtime:          r.GetRuntime(),
+		VoteAverage:      r.VoteAverage,
+		Tagline:          r.Tagline,
+		OriginalTitle:    r.GetOriginalTitle(),
+		OriginalLanguage: r.OriginalLanguage,
+		IMDbID:           r.IMDbID,
+		Overview:         r.Overview,
+		Path:             r.Path,
+		HasPoster:        r.HasPoster,
+		Url:              r.Url(),
+	}
+}
+
+// listWriter prints the rows of list.
+type listWriter func(w io.Writer, rows []listRow) error
+
+// newListWriter returns the writer of format: table, json, csv, or else a text/template executed for each row.
+func newListWriter(format string) (listWriter, error) {
+	switch format {
+	case "table":
+		return writeListTable, nil
+	case "json":
+		return writeListJSON, nil
+	case "csv":
+		return writeListCSV, nil
+	}
+	tmpl, err := template.New("list").Funcs(template.FuncMap{"join": strings.Join}).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, rows []listRow) error {
+		for _, row := range rows {
+			if err := tmpl.Execute(w, row); err != nil {
+				return err
+			}
+			// Like docker, templates print a line per row.
+			if !strings.HasSuffix(format, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// writeListTable prints rows as aligned columns, with the main fields only.
+func writeListTable(w io.Writer, rows []listRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tTITLE\tYEAR\tDIRECTOR\tGENRES\tPATH")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", r.ID, r.MediaType, r.Title, r.Year, r.Director, strings.Join(r.Genres, ", "), r.Path)
+	}
+	return tw.Flush()
+}
+
+// writeListJSON prints rows as an indented JSON array.
+func writeListJSON(w io.Writer, rows []listRow) error {
+	if rows == nil {
+		rows = []listRow{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// listCSVHeader are the columns of the CSV output, named after the JSON keys.
+var listCSVHeader = []string{"id", "media_type", "title", "year", "release_date", "director", "genres", "runtime", "vote_average",
+	"tagline", "original_title", "original_language", "imdb_id", "overview", "path", "has_poster", "url"}
+
+// writeListCSV prints rows as CSV with a header line. Genres are separated by |.
+func writeListCSV(w io.Writer, rows []listRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(listCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		record := []string{strconv.Itoa(r.ID), r.MediaType, r.Title, strconv.Itoa(r.Year), r.ReleaseDate, r.Director,
+			strings.Join(r.Genres, "|"), strconv.Itoa(r.Runtime), strconv.FormatFloat(r.VoteAverage, 'f', -1, 64),
+			r.Tagline, r.OriginalTitle, r.OriginalLanguage, r.IMDbID, r.Overview, r.Path, strconv.FormatBool(r.HasPoster), r.Url}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// parseYearRange reads a year, e.g. 1995, or an inclusive range of years, e.g. 1990..1999, 1990.. or ..1999.
+// An open end of a range is 0.
+func parseYearRange(s string) (minYear int, maxYear int, err error) {
+	first, last, isRange := strings.Cut(s, "..")
+	if !isRange {
+		last = first
+	}
+	if first == "" && last == "" {
+		return 0, 0, fmt.Errorf("year range %q has no bound", s)
+	}
+	parse := func(year string) (int, error) {
+		if year == "" {
+			return 0, nil
+		}
+		y, err := strconv.Atoi(year)
+		if err != nil || !medianame.IsValidYear(y) {
+			return 0, fmt.Errorf("%q isn't a valid year", year)
+		}
+		return y, nil
+	}
+	if minYear, err = parse(first); err != nil {
+		return 0, 0, err
+	}
+	if maxYear, err = parse(last); err != nil {
+		return 0, 0, err
+	}
+	if minYear != 0 && maxYear != 0 && minYear > maxYear {
+		return 0, 0, fmt.Errorf("year range %q ends before it starts", s)
+	}
+	return minYear, maxYear, nil
+}
+
+// parseMediaFilter reads the filter flags of list.
+func parseMediaFilter(cmd *cobra.Command) (db.MediaFilter, error) {
+	var f db.MediaFilter
+	flags := cmd.Flags()
+	var err error
+	if f.MediaType, err = flags.GetString("type"); err != nil {
+		return f, err
+	}
+	if f.MediaType != "" && f.MediaType != api.MediaTypeMovie && f.MediaType != api.MediaTypeTV {
+		return f, fmt.Errorf("unknown media type %v, must be %v or %v", f.MediaType, api.MediaTypeMovie, api.MediaTypeTV)
+	}
+	if years, err := flags.GetString("year"); err != nil {
+		return f, err
+	} else if years != "" {
+		if f.MinYear, f.MaxYear, err = parseYearRange(years); err != nil {
+			return f, err
+		}
+	}
+	if f.Title, err = flags.GetString("title"); err != nil {
+		return f, err
+	}
+	if f.Director, err = flags.GetString("director"); err != nil {
+		return f, err
+	}
+	if f.Genre, err = flags.GetString("genre"); err != nil {
+		return f, err
+	}
+	if f.MissingPoster, err = flags.GetBool("missing-poster"); err != nil {
+		return f, err
+	}
+	if f.Sort, err = flags.GetString("sort"); err != nil {
+		return f, err
+	}
+	if f.Descending, err = flags.GetBool("desc"); err != nil {
+		return f, err
+	}
+	if f.Limit, err = flags.GetInt("limit"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"query"},
+	Short:   "Lists the media of the database matching filters",
+	Long: `Lists the media of the database matching all the given filters, e.g.
+  mymedia list --type movie --year 1990..1999 --director kubrick --genre drama
+--year takes a year or a range: 1990..1999, 1990.. or ..1999. --title and --director match part of the name, --genre the whole genre.
+
+--format is table, json, csv, or a Go text/template executed for each media, e.g. '{{.Title}} ({{.Year}}) {{join .Genres ", "}}'.
+The fields are those of the JSON output, in CamelCase: ID, MediaType, Title, Year, ReleaseDate, Director, Genres, Runtime, VoteAverage,
+Tagline, OriginalTitle, OriginalLanguage, IMDbID, Overview, Path, HasPoster, Url.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := parseMediaFilter(cmd)
+		if err != nil {
+			log.Fatalln(" Invalid filter: ", err)
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			log.Fatalln(" Couldn't read format flag from config")
+		}
+		write, err := newListWriter(format)
+		if err != nil {
+			log.Fatalln(" Invalid format: ", err)
+		}
+		records, err := localConfig.DBH.ListMedia(filter)
+		if err != nil {
+			log.Fatalln(" Couldn't list media: ", err)
+		}
+		rows := make([]listRow, 0, len(records))
+		for _, r := range records {
+			rows = append(rows, newListRow(r))
+		}
+		if err := write(os.Stdout, rows); err != nil {
+			log.Fatalln(" Couldn't print media: ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().String("type", "", "only list media of this type: movie or tv")
+	listCmd.Flags().String("year", "", "only list media released this year or in this range of years, e.g. 1990..1999")
+	listCmd.Flags().String("title", "", "only list media whose title contains this")
+	listCmd.Flags().String("director", "", "only list media whose director's name contains this")
+	listCmd.Flags().String("genre", "", "only list media of this genre")
+	listCmd.Flags().Bool("missing-poster", false, "only list media without poster")
+	listCmd.Flags().String("sort", "title", "sort by one of "+strings.Join(db.SortFields, ", "))
+	listCmd.Flags().Bool("desc", false, "sort in descending order")
+	listCmd.Flags().Int("limit", 0, "list at most this many media, 0 means no limit")
+	listCmd.Flags().String("format", "table", "output format: table, json, csv or a Go template")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseYearRange(t *testing.T) {
+	tests := []struct {
+		s        string
+		min, max int
+		wantErr  bool
+	}{
+		{"1995", 1995, 1995, false},
+		{"1990..1999", 1990, 1999, false},
+		{"1990..", 1990, 0, false},
+		{"..1999", 0, 1999, false},
+		{"..", 0, 0, true},
+		{"", 0, 0, true},
+		{"1999..1990", 0, 0, true},
+		{"nineties", 0, 0, true},
+		{"90..99", 0, 0, true},
+	}
+	for _, test := range tests {
+		min, max, err := parseYearRange(test.s)
+		if (err != nil) != test.wantErr || min != test.min || max != test.max {
+			t.Errorf("parseYearRange(%q) = %v, %v, %v, want %v, %v, error %v", test.s, min, max, err, test.min, test.max, test.wantErr)
+		}
+	}
+}
+
+func TestListWriters(t *testing.T) {
+	rows := []listRow{
+		{ID: 348, MediaType: "movie", Title: "Alien", Year: 1979, Director: "Ridley Scott", Genres: []string{"Horror", "Science Fiction"}, Path: "/films/Alien (1979)", HasPoster: true},
+		{ID: 949, MediaType: "movie", Title: "Heat, the movie", Year: 1995, Genres: []string{}, Path: "/films/Heat (1995)"},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"table", `ID   TYPE   TITLE            YEAR  DIRECTOR      GENRES                   PATH
+348  movie  Alien            1979  Ridley Scott  Horror, Science Fiction  /films/Alien (1979)
+949  movie  Heat, the movie  1995                                         /films/Heat (1995)
+`},
+		{"csv", `id,media_type,title,year,release_date,director,genres,runtime,vote_average,tagline,original_title,original_language,imdb_id,overview,path,has_poster,url
+348,movie,Alien,1979,,Ridley Scott,Horror|Science Fiction,0,0,,,,,,/films/Alien (1979),true,
+949,movie,"Heat, the movie",1995,,,,0,0,,,,,,/films/Heat (1995),false,
+`},
+		{`{{.Title}} ({{.Year}}): {{join .Genres ", "}}`, `Alien (1979): Horror, Science Fiction
+Heat, the movie (1995): 
+`},
+		{"{{.ID}}\n", "348\n949\n"},
+	}
+	for _, test := range tests {
+		write, err := newListWriter(test.format)
+		if err != nil {
+			t.Fatalf("newListWriter(%q): %v", test.format, err)
+		}
+		var b bytes.Buffer
+		if err := write(&b, rows); err != nil {
+			t.Fatalf("format %q: %v", test.format, err)
+		}
+		if b.String() != test.want {
+			t.Errorf("format %q printed\n%v\nwant\n%v", test.format, b.String(), test.want)
+		}
+	}
+
+	write, err := newListWriter("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := write(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []listRow
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[0].Genres[1] != "Science Fiction" || !decoded[0].HasPoster {
+		t.Errorf("JSON decoded to %+v, %v", decoded, err)
+	}
+	b.Reset()
+	if err := write(&b, nil); err != nil || b.String() != "[]\n" {
+		t.Errorf("JSON of no rows is %q, %v, want []", b.String(), err)
+	}
+
+	if _, err := newListWriter("{{.Title"); err == nil {
+		t.Error("invalid template: got no error")
+	}
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/medianame"
+	"github.com/spf13/cobra"
+)
+
+// defaultOrganizeTemplate tags folders with the TMDB id of their media, or the IMDb id of media from OMDb, whose ids are negative.
+const defaultOrganizeTemplate = "{{.Title}} ({{.Year}}){{if gt .ID 0}} {tmdb-{{.ID}}}{{else if .IMDbID}} {imdb-{{.IMDbID}}}{{end}}"
+
+// organizePlan is the move of a media folder to its canonical path.
+type organizePlan struct {
+	Record   db.Record
+	Old, New string
+	// OldVideo and NewVideo are the paths of the main video file in the moved folder, if it's renamed too.
+	OldVideo, NewVideo string
+	// Sidecar is written to the moved folder, if the new name pins a TV show to its TMDB id, see medianame.SidecarFile.
+	Sidecar string
+	// Skip tells why the folder isn't moved, if it isn't.
+	Skip string
+}
+
+// newOrganizeTemplate parses text, a text/template executed with the fields of list's templates.
+func newOrganizeTemplate(text string) (*template.Template, error) {
+	return template.New("organize").Funcs(template.FuncMap{"join": strings.Join}).Option("missingkey=error").Parse(text)
+}
+
+// canonicalPath returns the path of the folder of r under root, given by tmpl.
+// The fields of r are sanitized, so that a title can't add a folder, but a / in tmpl does.
+func canonicalPath(tmpl *template.Template, root string, r db.Record) (string, error) {
+	row := newListRow(r)
+	row.Title, row.OriginalTitle, row.Director = medianame.Sanitize(row.Title), medianame.Sanitize(row.OriginalTitle), medianame.Sanitize(row.Director)
+	for i, g := range row.Genres {
+		row.Genres[i] = medianame.Sanitize(g)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, row); err != nil {
+		return "", err
+	}
+	elements := strings.Split(b.String(), "/")
+	for i, e := range elements {
+		elements[i] = medianame.Sanitize(e)
+		if elements[i] == "" || elements[i] == ".." {
+			return "", fmt.Errorf("template gives %q, with an empty or parent folder", b.String())
+		}
+	}
+	return filepath.Join(append([]string{root}, elements...)...), nil
+}
+
+// mainVideoFile returns the path of the largest video file right in dir, or an empty path if there's none.
+func mainVideoFile(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var main string
+	var size int64 = -1
+	for _, e := range entries {
+		if e.IsDir() || !medianame.IsVideoFile(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		if info.Size() > size {
+			main, size = filepath.Join(dir, e.Name()), info.Size()
+		}
+	}
+	return main, nil
+}
+
+// planOrganize plans the move of the media folders under root to the path given by tmpl.
+// With renameVideos, the main video file of a movie is renamed after its folder too.
+// Folders whose new name wouldn't be read back by scan, or whose new path is taken, are skipped.
+func planOrganize(dbh *db.DBHandler, root string, tmpl *template.Template, renameVideos bool) ([]organizePlan, error) {
+	records, err := dbh.ListMedia(db.MediaFilter{Sort: "path"})
+	if err != nil {
+		return nil, fmt.Errorf("listing media: %w", err)
+	}
+	var plans []organizePlan
+	// targets are the new paths of the plans so far.
+	targets := make(map[string]string)
+	for _, r := range records {
+		if !isUnder(root, r.Path) || r.Path == root {
+			continue
+		}
+		p := organizePlan{Record: r, Old: r.Path}
+		plans = append(plans, p)
+		plan := &plans[len(plans)-1]
+		if _, err := os.Stat(r.Path); err != nil {
+			plan.Skip = fmt.Sprintf("can't read folder: %v", err)
+			continue
+		}
+		if plan.New, err = canonicalPath(tmpl, root, r); err != nil {
+			plan.Skip = err.Error()
+			continue
+		}
+		name := filepath.Base(plan.New)
+		info, _ := medianame.Parse(name)
+		_, sidecarErr := os.Stat(filepath.Join(r.Path, medianame.SidecarFile))
+		hasSidecar := sidecarErr == nil
+		if info.TMDBID == 0 && info.IMDbID == "" && (info.Title == "" || info.Year == 0) && !hasSidecar {
+			plan.Skip = fmt.Sprintf("new name %q has no id, nor title and year", name)
+			continue
+		}
+		// A TMDB id in a name reads as a movie's.
+		if r.MediaType == api.MediaTypeTV && info.TMDBID != 0 && !hasSidecar {
+			plan.Sidecar = fmt.Sprintf("%v/%v\n", r.MediaType, r.ID)
+		}
+		if other, ok := targets[plan.New]; ok {
+			plan.Skip = fmt.Sprintf("%v goes to the same path", other)
+			continue
+		}
+		targets[plan.New] = r.Path
+		if plan.New != plan.Old {
+			if _, err := os.Lstat(plan.New); err == nil {
+				plan.Skip = plan.New + " already exists"
+				continue
+			}
+		}
+		if renameVideos && r.MediaType == api.MediaTypeMovie {
+			video, err := mainVideoFile(r.Path)
+			if err != nil {
+				plan.Skip = fmt.Sprintf("can't read folder: %v", err)
+				continue
+			}
+			if video != "" && strings.TrimSuffix(filepath.Base(video), filepath.Ext(video)) != name {
+				plan.OldVideo, plan.NewVideo = video, filepath.Join(r.Path, name+filepath.Ext(video))
+				if _, err := os.Lstat(plan.NewVideo); err == nil {
+					plan.Skip = plan.NewVideo + " already exists"
+					continue
+				}
+			}
+		}
+	}
+	return plans, nil
+}
+
+// applyOrganize moves the folder of p, renames its video file and updates the paths of the db.
+// The filesystem is restored if a step fails.
+func applyOrganize(dbh *db.DBHandler, p organizePlan) (err error) {
+	if p.Skip != "" {
+		return nil
+	}
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				err = fmt.Errorf("%w, and couldn't undo it: %v", err, undoErr)
+			}
+		}
+	}()
+	if p.OldVideo != "" {
+		if err := os.Rename(p.OldVideo, p.NewVideo); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return os.Rename(p.NewVideo, p.OldVideo) })
+	}
+	if p.Sidecar != "" {
+		sidecar := filepath.Join(p.Old, medianame.SidecarFile)
+		if err := os.WriteFile(sidecar, []byte(p.Sidecar), 0o644); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return os.Remove(sidecar) })
+	}
+	if p.Old != p.New {
+		if err := os.MkdirAll(filepath.Dir(p.New), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(p.Old, p.New); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return os.Rename(p.New, p.Old) })
+		if _, _, err := dbh.Relocate(p.Old, p.New, func(db.PathRewrite) bool { return true }, false); err != nil {
+			return fmt.Errorf("DB write error: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeOrganizePlans prints the plans that change something to w.
+func writeOrganizePlans(w io.Writer, plans []organizePlan) {
+	for _, p := range plans {
+		switch {
+		case p.Skip != "":
+			fmt.Fprintf(w, "∅ %v: %v\n", p.Old, p.Skip)
+		case p.Old != p.New:
+			fmt.Fprintf(w, "✓ %v → %v\n", p.Old, p.New)
+		}
+		if p.Skip == "" && p.OldVideo != "" {
+			fmt.Fprintf(w, "  %v → %v\n", filepath.Base(p.OldVideo), filepath.Base(p.NewVideo))
+		}
+		if p.Skip == "" && p.Sidecar != "" {
+			fmt.Fprintf(w, "  %v: %v", medianame.SidecarFile, p.Sidecar)
+		}
+	}
+}
+
+// organizeCmd represents the organize command
+var organizeCmd = &cobra.Command{
+	Use:   "organize [root]",
+	Short: "Renames the media folders after their match",
+	Long: `Moves the folders of the media of the database under root (default: current folder) to the path given by --template, under root.
+--template is a Go text/template with the fields of list --format, the default is '` + defaultOrganizeTemplate + `'.
+The fields are sanitized for the filesystem, a / in the template makes subfolders, e.g. '{{.MediaType}}/{{.Title}} ({{.Year}})'.
+A folder is skipped if its new name wouldn't give its media to scan, or if its new path is taken.
+A TV show named with its TMDB id gets a .tmdb file, since ids in names are read as movie ids.
+With --videos, the main video file of a movie is renamed after its folder.
+The paths of the database are updated with each move. With --dry-run, the moves are printed but not done.`,
+	Example: `  mymedia organize --dry-run /mnt/films
+  mymedia organize --template '{{.Title}} ({{.Year}})' --videos /mnt/films`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		text, err := cmd.Flags().GetString("template")
+		if err != nil {
+			log.Fatalln(" Couldn't read template flag from config")
+		}
+		tmpl, err := newOrganizeTemplate(text)
+		if err != nil {
+			log.Fatalln(" Invalid template: ", err)
+		}
+		videos, err := cmd.Flags().GetBool("videos")
+		if err != nil {
+			log.Fatalln(" Couldn't read videos flag from config")
+		}
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalln(" Couldn't read dry-run flag from config")
+		}
+		root := "."
+		if len(args) == 1 {
+			root = args[0]
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			log.Fatalln(" Couldn't resolve library root: ", err)
+		}
+		plans, err := planOrganize(localConfig.DBH, root, tmpl, videos)
+		if err != nil {
+			log.Fatalln(" Couldn't plan moves: ", err)
+		}
+		writeOrganizePlans(os.Stdout, plans)
+		if dryRun {
+			return
+		}
+		var moved, failed int
+		for _, p := range plans {
+			if p.Skip != "" || (p.Old == p.New && p.OldVideo == "" && p.Sidecar == "") {
+				continue
+			}
+			if err := applyOrganize(localConfig.DBH, p); err != nil {
+				log.Printf(" Couldn't move %v: %v\n", p.Old, err)
+				failed++
+				continue
+			}
+			moved++
+		}
+		fmt.Printf("Organized %v folders, %v failed\n", moved, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(organizeCmd)
+
+	organizeCmd.Flags().String("template", defaultOrganizeTemplate, "path of a media folder under root, a Go template with the fields of list --format")
+	organizeCmd.Flags().Bool("videos", false, "rename the main video file of movies after their folder")
+	organizeCmd.Flags().Bool("dry-run", false, "print the moves without doing them")
+}
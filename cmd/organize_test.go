@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/medianame"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	faceOff := db.Record{Media: api.Media{ID: 754, MediaType: api.MediaTypeMovie, Title: "Face/Off", ReleaseDate: "1997-06-27", IMDbID: "tt0119094"}}
+	// Found on OMDb.
+	alien := db.Record{Media: api.Media{ID: -78748, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", IMDbID: "tt0078748"}}
+	tests := []struct {
+		template string
+		record   db.Record
+		want     string
+		wantErr  bool
+	}{
+		{defaultOrganizeTemplate, faceOff, "/films/Face-Off (1997) {tmdb-754}", false},
+		{defaultOrganizeTemplate, alien, "/films/Alien (1979) {imdb-tt0078748}", false},
+		{"{{.MediaType}}/{{.Title}} ({{.Year}})", faceOff, "/films/movie/Face-Off (1997)", false},
+		{"{{.Director}}/{{.Title}}", faceOff, "", true},
+		{"../{{.Title}}", faceOff, "", true},
+		{"{{.Nope}}", faceOff, "", true},
+	}
+	for _, tt := range tests {
+		tmpl, err := newOrganizeTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := canonicalPath(tmpl, "/films", tt.record)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("canonicalPath(%q) = %q, %v, want %q (error: %v)", tt.template, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestOrganize(t *testing.T) {
+	_, dbh := newTestScanner(t, acceptAuto)
+	root := t.TempDir()
+	files := map[string]string{
+		"A/Alien.1979.1080p.BluRay/alien.1979.1080p.mkv":    "the movie",
+		"A/Alien.1979.1080p.BluRay/sample.mkv":              "s",
+		"The Expanse/S01E01.mkv":                            "episode",
+		"Heat (1995) {tmdb-949}/Heat (1995) {tmdb-949}.mkv": "organized",
+		"Taken/.keep": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	show := api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14"}
+	for path, m := range map[string]api.Media{
+		"A/Alien.1979.1080p.BluRay": {ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"},
+		"The Expanse":               show,
+		"Heat (1995) {tmdb-949}":    {ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"},
+		"Taken":                     {ID: 8681, MediaType: api.MediaTypeMovie, Title: "Taken", ReleaseDate: "2008-02-18"},
+	} {
+		if _, err := dbh.WriteToDB(m, filepath.Join(root, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dbh.WriteEpisode(show.ID, api.Episode{SeasonNumber: 1, EpisodeNumber: 1}, filepath.Join(root, "The Expanse/S01E01.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	// Taken's new path is taken.
+	if err := os.Mkdir(filepath.Join(root, "Taken (2008) {tmdb-8681}"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := newOrganizeTemplate(defaultOrganizeTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans, err := planOrganize(dbh, root, tmpl, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range plans {
+		if err := applyOrganize(dbh, p); err != nil {
+			t.Errorf("%v: %v", p.Old, err)
+		}
+	}
+
+	for _, name := range []string{
+		"Alien (1979) {tmdb-348}/Alien (1979) {tmdb-348}.mkv",
+		"Alien (1979) {tmdb-348}/sample.mkv",
+		"The Expanse (2015) {tmdb-63639}/S01E01.mkv",
+		"Heat (1995) {tmdb-949}/Heat (1995) {tmdb-949}.mkv",
+		"Taken/.keep",
+	} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	sidecar, err := os.ReadFile(filepath.Join(root, "The Expanse (2015) {tmdb-63639}", medianame.SidecarFile))
+	if err != nil || strings.TrimSpace(string(sidecar)) != "tv/63639" {
+		t.Errorf("got sidecar %q (error %v), want tv/63639", sidecar, err)
+	}
+	want := map[int]string{348: "Alien (1979) {tmdb-348}", 949: "Heat (1995) {tmdb-949}", 8681: "Taken", 63639: "The Expanse (2015) {tmdb-63639}"}
+	records, err := dbh.ListMedia(db.MediaFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.Path != filepath.Join(root, want[r.ID]) {
+			t.Errorf("%v is at %v, want %v", r.GetTitle(), r.Path, want[r.ID])
+		}
+	}
+	if episodes, err := dbh.Episodes(show.ID); err != nil || len(episodes) != 1 || episodes[0].Path != filepath.Join(root, want[63639], "S01E01.mkv") {
+		t.Errorf("got episodes %+v (error %v), want S01E01 moved", episodes, err)
+	}
+	// The moved folders read back as their media.
+	for id, name := range want {
+		f, err := readMediaFolder(filepath.Join(root, name))
+		if id != 8681 && (err != nil || f.TMDBID != id) {
+			t.Errorf("read %v as %+v (error %v), want TMDB id %v", name, f, err, id)
+		}
+	}
+}
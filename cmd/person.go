@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// personCmd represents the person command
+var personCmd = &cobra.Command{
+	Use:   "person <name>",
+	Short: "Lists the media a person worked on",
+	Long: `Lists the media of the database that the people whose name contains <name> worked on, with their jobs.
+Credits hold the top billed cast, directors, writers and composers, they're written by scan.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.Join(args, " ")
+		credits, err := localConfig.DBH.PersonCredits(name)
+		if err != nil {
+			log.Fatalln(" Couldn't read credits: ", err)
+		}
+		if len(credits) == 0 {
+			fmt.Printf("∅ Found no one named %q in the database.\n", name)
+			return
+		}
+		printPersonCredits(credits)
+	},
+}
+
+// printPersonCredits prints credits by person, one media per line with the jobs of the person on it.
+// credits must be ordered by person then media, as given by PersonCredits.
+func printPersonCredits(credits []db.PersonCredit) {
+	for i := 0; i < len(credits); {
+		c := credits[i]
+		if i == 0 || credits[i-1].PersonID != c.PersonID {
+			fmt.Printf("%v\n", c.Name)
+		}
+		var jobs []string
+		for ; i < len(credits) && credits[i].PersonID == c.PersonID && credits[i].Media.ID == c.Media.ID; i++ {
+			jobs = append(jobs, credits[i].Credit.String())
+		}
+		m := c.Media
+		fmt.Printf("  %v «%v» (%v): %v @ %v\n", api.MediaTypeIcons[m.MediaType], m.GetTitle(), m.GetYear(), strings.Join(jobs, ", "), m.Path)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(personCmd)
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
 	"github.com/spf13/cobra"
 
 	fzf "github.com/junegunn/fzf/src"
@@ -13,45 +17,32 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+const posterFilePath = "/tmp/mymedia_poster.jpg"
+
+// defaultThumbnailWidth is the width of the posters previewed by picker.
+const defaultThumbnailWidth = 200
+
 var pickerCmd = &cobra.Command{
 	Use:   "picker",
 	Short: "TUI to query the database",
 	Long: `Provides a fzf-based TUI to query the database.
 The output will be the path to the selected media directory.
-External dependencies: fold, kitty
+Selecting a TV show with episode files opens a picker on its episodes, the selected episode is played with --player.
+Posters are previewed as thumbnails --thumbnail-width wide, made and stored in the database on first use.
+External dependencies: fold, kitty, sqlite3, mpv (to play episodes)
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-
-		inputChan := make(chan string)
-		go func() {
-			query := "SELECT title, year, overview, director, id, path FROM media ORDER BY title, year ASC"
-			rows, err := localConfig.DBH.DB.Query(query)
-			if err != nil {
-				log.Fatal("Query error : ", err)
-			}
-			for rows.Next() {
-				var title, overview, director, path string
-				var year, id int
-				if err := rows.Scan(&title, &year, &overview, &director, &id, &path); err != nil {
-					log.Fatal(err)
-				}
-				if director != "" {
-					director = " -- " + director
-				}
-				s := fmt.Sprintf("%v\t%v (%v)%v\t%v\t%v\t%v", title, title, year, director, overview, id, path)
-				inputChan <- s
-			}
-			close(inputChan)
-		}()
-
-		outputChan := make(chan string)
-		go func() {
-			for s := range outputChan {
-				path := strings.FieldsFunc(s, func(r rune) bool { return r == '\t' })[4]
-				fmt.Println(path)
-			}
-		}()
-
+		player, err := cmd.Flags().GetString("player")
+		if err != nil {
+			log.Fatalln(" Couldn't read player flag from config")
+		}
+		thumbnailWidth, err := cmd.Flags().GetInt("thumbnail-width")
+		if err != nil {
+			log.Fatalln(" Couldn't read thumbnail-width flag from config")
+		}
+		if thumbnailWidth <= 0 {
+			log.Fatalln(" --thumbnail-width must be positive")
+		}
 		exit := func(code int, err error) {
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err.Error())
@@ -59,30 +50,141 @@ External dependencies: fold, kitty
 			os.Exit(code)
 		}
 
-		cmdLineOptions := []string{"--delimiter=\\t", "--with-nth=1"}
-
-		posterFilePath := "/tmp/mymedia_poster.jpg"
-		query := fmt.Sprintf(`SELECT writefile("%v", poster) FROM media WHERE id={-2}`, posterFilePath)
-		cmdLineOptions = append(cmdLineOptions, `--bind=focus:execute-silent(sqlite3 `+localConfig.DBH.Path+` '`+query+`')`)
-		previewCmd := "echo {2};echo;echo {3}|fold -w ${FZF_PREVIEW_COLUMNS} -s;COLS=$((LINES*2/3));kitten icat --clear --transfer-mode=memory --stdin=no --unicode-placeholder --place=${COLS}x${FZF_PREVIEW_LINES}@0x0 " + posterFilePath
-		cmdLineOptions = append(cmdLineOptions, "--preview="+previewCmd)
-		// Build fzf.Options
-		options, err := fzf.ParseOptions(
-			true, // whether to load defaults ($FZF_DEFAULT_OPTS_FILE and $FZF_DEFAULT_OPTS)
-			cmdLineOptions,
-		)
+		if _, err := localConfig.DBH.MakeThumbnails(thumbnailWidth); err != nil {
+			log.Println(" Couldn't make thumbnails, previewing full posters: ", err)
+		}
+		selected, code, err := pickMedia(thumbnailWidth)
+		if err != nil || selected == "" {
+			exit(code, err)
+		}
+		// Lines end with media type, id and path.
+		fields := strings.Split(selected, "\t")
+		mediaType, path := fields[len(fields)-3], fields[len(fields)-1]
+		id, err := strconv.Atoi(fields[len(fields)-2])
 		if err != nil {
 			exit(fzf.ExitError, err)
 		}
+		if mediaType == api.MediaTypeTV {
+			episode, code, err := pickEpisode(id)
+			if err != nil || (episode == "" && code != fzf.ExitOk) {
+				exit(code, err)
+			}
+			if episode != "" {
+				if err := play(player, episode); err != nil {
+					exit(fzf.ExitError, err)
+				}
+				return
+			}
+		}
+		fmt.Println(path)
+	},
+}
+
+// pickMedia lets the user pick a media of the db, and returns the selected line.
+// Posters are previewed as their thumbnail thumbnailWidth wide, if it was made, see db.MakeThumbnails.
+func pickMedia(thumbnailWidth int) (string, int, error) {
+	inputChan := make(chan string)
+	go func() {
+		query := "SELECT title, year, overview, director, media_type, id, path FROM media ORDER BY title, year ASC"
+		rows, err := localConfig.DBH.DB.Query(query)
+		if err != nil {
+			log.Fatal("Query error : ", err)
+		}
+		for rows.Next() {
+			var title, overview, director, mediaType, path string
+			var year, id int
+			if err := rows.Scan(&title, &year, &overview, &director, &mediaType, &id, &path); err != nil {
+				log.Fatal(err)
+			}
+			if director != "" {
+				director = " -- " + director
+			}
+			s := fmt.Sprintf("%v\t%v (%v)%v\t%v\t%v\t%v\t%v", title, title, year, director, overview, mediaType, id, path)
+			inputChan <- s
+		}
+		close(inputChan)
+	}()
+
+	cmdLineOptions := []string{"--delimiter=\\t", "--with-nth=1"}
+	query := fmt.Sprintf(`SELECT writefile("%v", coalesce((SELECT bytes FROM artwork WHERE media_id=media.id AND kind="%v" AND size="%v"), poster)) FROM media WHERE id={-2}`,
+		posterFilePath, api.ArtworkThumbnail, db.ThumbnailSize(thumbnailWidth))
+	cmdLineOptions = append(cmdLineOptions, `--bind=focus:execute-silent(sqlite3 `+localConfig.DBH.Path+` '`+query+`')`)
+	previewCmd := "echo {2};echo;echo {3}|fold -w ${FZF_PREVIEW_COLUMNS} -s;COLS=$((LINES*2/3));kitten icat --clear --transfer-mode=memory --stdin=no --unicode-placeholder --place=${COLS}x${FZF_PREVIEW_LINES}@0x0 " + posterFilePath
+	cmdLineOptions = append(cmdLineOptions, "--preview="+previewCmd)
+	return runFzf(inputChan, cmdLineOptions)
+}
 
-		// Set up input and output channels
-		options.Input = inputChan
-		options.Output = outputChan
+// pickEpisode lets the user pick an episode file of the TV show with id showID, and returns its path.
+// The path is empty if the show has no episode file.
+func pickEpisode(showID int) (string, int, error) {
+	episodes, err := localConfig.DBH.Episodes(showID)
+	if err != nil {
+		return "", fzf.ExitError, err
+	}
+	inputChan := make(chan string, len(episodes))
+	for _, e := range episodes {
+		if e.Path == "" {
+			continue
+		}
+		inputChan <- fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v", e, e.AirDate, e.Overview, e.SeasonNumber, e.EpisodeNumber, e.Path)
+	}
+	close(inputChan)
+	if len(inputChan) == 0 {
+		return "", fzf.ExitOk, nil
+	}
+
+	cmdLineOptions := []string{"--delimiter=\\t", "--with-nth=1", "--no-sort"}
+	query := fmt.Sprintf(`SELECT writefile("%v", still) FROM episodes WHERE show_id=%v AND season_number={-3} AND episode_number={-2}`, posterFilePath, showID)
+	cmdLineOptions = append(cmdLineOptions, `--bind=focus:execute-silent(sqlite3 `+localConfig.DBH.Path+` '`+query+`')`)
+	previewCmd := "echo {1};echo {2};echo;echo {3}|fold -w ${FZF_PREVIEW_COLUMNS} -s;COLS=$((LINES*2/3));kitten icat --clear --transfer-mode=memory --stdin=no --unicode-placeholder --place=${COLS}x${FZF_PREVIEW_LINES}@0x0 " + posterFilePath
+	cmdLineOptions = append(cmdLineOptions, "--preview="+previewCmd)
+	selected, code, err := runFzf(inputChan, cmdLineOptions)
+	if err != nil || selected == "" {
+		return "", code, err
+	}
+	fields := strings.Split(selected, "\t")
+	return fields[len(fields)-1], code, nil
+}
 
-		// Run fzf
-		code, err := fzf.Run(options)
-		exit(code, err)
-	},
+// runFzf runs fzf on the lines of inputChan with cmdLineOptions, and returns the selected line with fzf's exit code.
+func runFzf(inputChan chan string, cmdLineOptions []string) (string, int, error) {
+	// Build fzf.Options
+	options, err := fzf.ParseOptions(
+		true, // whether to load defaults ($FZF_DEFAULT_OPTS_FILE and $FZF_DEFAULT_OPTS)
+		cmdLineOptions,
+	)
+	if err != nil {
+		return "", fzf.ExitError, err
+	}
+
+	// Set up input and output channels
+	outputChan := make(chan string)
+	selected := make(chan string)
+	go func() {
+		var s string
+		for line := range outputChan {
+			s = line
+		}
+		selected <- s
+	}()
+	options.Input = inputChan
+	options.Output = outputChan
+
+	// Run fzf
+	code, err := fzf.Run(options)
+	close(outputChan)
+	return <-selected, code, err
+}
+
+// play opens the file at path with player, or prints path if player is empty.
+func play(player string, path string) error {
+	if player == "" {
+		fmt.Println(path)
+		return nil
+	}
+	c := exec.Command(player, path)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return c.Run()
 }
 
 func init() {
@@ -96,5 +198,6 @@ func init() {
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	// pickerCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	pickerCmd.Flags().String("player", "mpv", "command playing the selected episode, an empty one prints its path instead")
+	pickerCmd.Flags().Int("thumbnail-width", defaultThumbnailWidth, "width of the previewed posters, in pixels")
 }
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type acceptMode int
+
+const (
+	// acceptAsk prompts the user on stdin.
+	acceptAsk acceptMode = iota
+	// acceptYes answers yes to every prompt.
+	acceptYes
+	// acceptNo answers no to every prompt.
+	acceptNo
+	// acceptAuto writes confident matches only and queues the others for review.
+	acceptAuto
+)
+
+type decision int
+
+const (
+	decisionReject decision = iota
+	decisionAccept
+	decisionReview
+)
+
+// acceptPolicy answers the questions asked by the scan pipeline.
+type acceptPolicy struct {
+	mode acceptMode
+	// ask is used to prompt the user in acceptAsk mode.
+	ask func(prompt string) bool
+}
+
+// newAcceptPolicy reads the --yes, --no and --auto flags of cmd.
+// ask is used if none of them is set.
+func newAcceptPolicy(cmd *cobra.Command, ask func(prompt string) bool) acceptPolicy {
+	p := acceptPolicy{mode: acceptAsk, ask: ask}
+	for flag, mode := range map[string]acceptMode{"yes": acceptYes, "no": acceptNo, "auto": acceptAuto} {
+		set, err := cmd.Flags().GetBool(flag)
+		if err != nil {
+			log.Fatalf(" Couldn't read %v flag from config\n", flag)
+		}
+		if set {
+			p.mode = mode
+		}
+	}
+	return p
+}
+
+// forDryRun returns p for a dry run if dryRun is set. Nothing is written, so nothing is asked:
+// questions are answered yes, to plan every write.
+func (p acceptPolicy) forDryRun(dryRun bool) acceptPolicy {
+	if dryRun && p.mode == acceptAsk {
+		p.mode = acceptYes
+	}
+	return p
+}
+
+// askQuitOnNo prompts like utils.AcceptOrQuit, quitting on anything but "y".
+func askQuitOnNo(prompt string) bool {
+	utils.AcceptOrQuit(prompt)
+	return true
+}
+
+// confirm answers a yes/no question. In acceptAuto mode, questions without a match to judge are answered no.
+func (p acceptPolicy) confirm(prompt string) bool {
+	switch p.mode {
+	case acceptYes:
+		return true
+	case acceptNo, acceptAuto:
+		return false
+	default:
+		return p.ask(prompt)
+	}
+}
+
+// titleMatches tells whether the title or original title of m is a case-insensitive match of title.
+// Folder names may be in either language.
+func titleMatches(m api.Media, title string) bool {
+	return strings.EqualFold(m.GetTitle(), title) || strings.EqualFold(m.GetOriginalTitle(), title)
+}
+
+// decideMatch tells whether match, found for title among candidates, should be written to DB.
+// In acceptAuto mode, match is accepted if its title or original title is an exact (case-insensitive) match of title,
+// its year is within tolerance of year, and no other candidate fits just as well.
+// Otherwise it goes to review and reason tells why.
+func (p acceptPolicy) decideMatch(title string, year int, tolerance int, match api.Media, candidates []api.Media) (d decision, reason string) {
+	if p.mode != acceptAuto {
+		if p.confirm("Write to DB ?") {
+			return decisionAccept, ""
+		}
+		return decisionReject, ""
+	}
+	if utils.Abs(match.GetYear()-year) > tolerance {
+		return decisionReview, fmt.Sprintf("closest match year %v is not within %v of %v", match.GetYear(), tolerance, year)
+	}
+	if !titleMatches(match, title) {
+		return decisionReview, fmt.Sprintf("title «%v» doesn't match exactly", match.GetTitle())
+	}
+	for _, c := range candidates {
+		if c.ID == match.ID && c.MediaType == match.MediaType {
+			continue
+		}
+		if titleMatches(c, title) && utils.Abs(c.GetYear()-year) <= tolerance {
+			return decisionReview, fmt.Sprintf("several matches, e.g. %v", c)
+		}
+	}
+	return decisionAccept, ""
+}
+
+// decidePinned tells whether a media pinned by id in its folder should be written to DB.
+// In acceptAuto mode it is, the id leaves no doubt.
+func (p acceptPolicy) decidePinned() decision {
+	if p.mode == acceptAuto || p.confirm("Write to DB ?") {
+		return decisionAccept
+	}
+	return decisionReject
+}
+
+func addAcceptPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("yes", false, "answer yes to every prompt")
+	cmd.Flags().Bool("no", false, "answer no to every prompt")
+	cmd.Flags().Bool("auto", false, "write confident matches only (exact title, year within tolerance), queue the others for review")
+	cmd.MarkFlagsMutuallyExclusive("yes", "no", "auto")
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -9,8 +11,9 @@ import (
 	"log"
 	"os"
 	"path"
-	"strings"
 
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/medianame"
 	"github.com/spf13/cobra"
 )
 
@@ -34,24 +37,46 @@ var posterCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalln(" Couldn't read title flag from config")
 		}
+		var year int
 		if title == "" {
 			cwd, err := os.Getwd()
 			if err != nil {
 				log.Fatalln(" Wrong args: title is empty and I can't get the cwd")
 			}
-			basePath := path.Base(cwd)
-			fields := strings.Fields(basePath)
-			if len(fields) < 2 {
-				log.Fatalf(" Cwd name is badly formatted, must be 'TITLE (YEAR)'")
+			info, err := medianame.Parse(path.Base(cwd))
+			if err != nil {
+				log.Fatalf(" Cwd %v\n", err)
 			}
-			title = strings.Join(fields[:len(fields)-1], " ")
+			title, year = info.Title, info.Year
+		}
+		// Without a year, any media with that title will do.
+		width, err := cmd.Flags().GetInt("width")
+		if err != nil {
+			log.Fatalln(" Couldn't read width flag from config")
 		}
-		query := "SELECT poster FROM media WHERE LOWER(media.title)=LOWER(?)"
-		row := localConfig.DBH.DB.QueryRow(query, title)
+		query := "SELECT id, poster FROM media WHERE LOWER(media.title)=LOWER(?) AND (?=0 OR media.year=?)"
+		row := localConfig.DBH.DB.QueryRow(query, title, year, year)
+		var id int
 		var poster []byte
-		if err := row.Scan(&poster); err != nil {
+		err = row.Scan(&id, &poster)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Ignore accents, punctuation and a leading article.
+			var record db.Record
+			var found bool
+			record, found, err = localConfig.DBH.FindMediaFuzzy(title, year, 0)
+			if err == nil && !found {
+				err = sql.ErrNoRows
+			}
+			id, poster = record.ID, record.PosterData
+		}
+		if err != nil {
 			log.Fatalf(" Couldn't get the poster from db for «%v»: %v", title, err)
 		}
+		if width > 0 {
+			if poster, err = localConfig.DBH.Thumbnail(id, width); err != nil {
+				log.Fatalf(" Couldn't make the thumbnail for «%v»: %v", title, err)
+			}
+		}
 		img, _, err := image.Decode(bytes.NewReader(poster))
 		if err != nil {
 			log.Fatalf(" Couldn't decode the poster for «%v»: %v", title, err)
@@ -81,4 +106,5 @@ func init() {
 	// is called directly, e.g.:
 	posterCmd.Flags().BoolP("replace", "r", false, "if replace is true, replace file if it exists")
 	posterCmd.Flags().StringP("title", "t", "", "media title, case insensitive, will be read from cwd name if missing")
+	posterCmd.Flags().Int("width", 0, "write a thumbnail of the poster this many pixels wide, stored in the db for next time")
 }
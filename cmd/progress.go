@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/briandowns/spinner"
+)
+
+// progress reports the advance of a scan on a single spinner line, printing logs above it through out.
+// It implements api.Logger. The spinner only shows on a terminal.
+type progress struct {
+	spinner *spinner.Spinner
+	out     api.Logger
+	total   int
+	// done is guarded by the spinner lock.
+	done int
+}
+
+func newProgress(total int, out api.Logger) *progress {
+	p := &progress{
+		spinner: spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(os.Stdout)),
+		out:     out,
+		total:   total,
+	}
+	p.spinner.Suffix = p.suffix()
+	return p
+}
+
+func (p *progress) suffix() string {
+	return fmt.Sprintf(" Scanned %v/%v folders", p.done, p.total)
+}
+
+func (p *progress) Start() { p.spinner.Start() }
+
+// Stop removes the spinner line.
+func (p *progress) Stop() { p.spinner.Stop() }
+
+// Printf prints a log line above the spinner.
+func (p *progress) Printf(format string, v ...any) {
+	p.spinner.Lock()
+	defer p.spinner.Unlock()
+	if p.spinner.Active() {
+		// Erase the spinner line, it's drawn again on the next frame.
+		fmt.Fprint(os.Stdout, "\r\033[K")
+	}
+	p.out.Printf(format, v...)
+}
+
+// Advance counts a folder as done.
+func (p *progress) Advance() {
+	p.spinner.Lock()
+	defer p.spinner.Unlock()
+	p.done++
+	p.spinner.Suffix = p.suffix()
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// targetExists tells whether the new path of r exists, only those rewrites are committed by relocate.
+func targetExists(r db.PathRewrite) bool {
+	_, err := os.Stat(r.New)
+	return err == nil
+}
+
+// relocate moves the paths of dbh from the folder from to the folder to, and prints the rewrites to w.
+// The rewrites whose new path doesn't exist are skipped. With dryRun, nothing is written.
+func relocate(dbh *db.DBHandler, from string, to string, dryRun bool, w io.Writer) (done []db.PathRewrite, skipped []db.PathRewrite, err error) {
+	if from, err = filepath.Abs(from); err != nil {
+		return nil, nil, err
+	}
+	if to, err = filepath.Abs(to); err != nil {
+		return nil, nil, err
+	}
+	if done, skipped, err = dbh.Relocate(from, to, targetExists, dryRun); err != nil {
+		return nil, nil, err
+	}
+	for _, r := range done {
+		fmt.Fprintf(w, "✓ %v: %v → %v\n", r.Table, r.Old, r.New)
+	}
+	for _, r := range skipped {
+		fmt.Fprintf(w, "∅ %v: %v not moved, %v doesn't exist\n", r.Table, r.Old, r.New)
+	}
+	verb := "Moved"
+	if dryRun {
+		verb = "Would move"
+	}
+	fmt.Fprintf(w, "%v %v paths, skipped %v\n", verb, len(done), len(skipped))
+	return done, skipped, nil
+}
+
+// relocateCmd represents the relocate command
+var relocateCmd = &cobra.Command{
+	Use:   "relocate --from <old root> --to <new root>",
+	Short: "Rewrites the paths of the database after moving the library",
+	Long: `Rewrites the paths of the media, episode files and review queue in the folder --from to the same place in the folder --to, in a single transaction.
+A path is only rewritten if its new path exists, move the files first. With --dry-run, the rewrites are printed but not written.`,
+	Example: `  mymedia relocate --from /mnt/old --to /srv/media --dry-run
+  mymedia relocate --from /mnt/old --to /srv/media`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			log.Fatalln(" Couldn't read from flag from config")
+		}
+		to, err := cmd.Flags().GetString("to")
+		if err != nil {
+			log.Fatalln(" Couldn't read to flag from config")
+		}
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			log.Fatalln(" Couldn't read dry-run flag from config")
+		}
+		if _, _, err := relocate(localConfig.DBH, from, to, dryRun, os.Stdout); err != nil {
+			log.Fatalln(" Couldn't relocate paths: ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relocateCmd)
+
+	relocateCmd.Flags().String("from", "", "folder the library was moved from")
+	relocateCmd.Flags().String("to", "", "folder the library was moved to")
+	relocateCmd.Flags().Bool("dry-run", false, "print the rewrites without writing them")
+	relocateCmd.MarkFlagRequired("from")
+	relocateCmd.MarkFlagRequired("to")
+}
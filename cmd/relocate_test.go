@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestRelocate(t *testing.T) {
+	_, dbh := newTestScanner(t, acceptAuto)
+	from, to := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(to, "Alien (1979)"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"}
+	heat := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"}
+	for _, m := range []api.Media{alien, heat} {
+		if _, err := dbh.WriteToDB(m, filepath.Join(from, m.Title+" ("+m.ReleaseDate[:4]+")")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Heat wasn't moved.
+	done, skipped, err := relocate(dbh, from, to, false, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].New != filepath.Join(to, "Alien (1979)") || len(skipped) != 1 || skipped[0].Old != filepath.Join(from, "Heat (1995)") {
+		t.Errorf("moved %+v and skipped %+v, want Alien moved and Heat skipped", done, skipped)
+	}
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 2 || rows[0].path != filepath.Join(to, "Alien (1979)") || rows[1].path != filepath.Join(from, "Heat (1995)") {
+		t.Errorf("got rows %+v", rows)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// reviewCmd represents the review command
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Lists the media folders queued for review by scan --auto",
+	Long: `Lists the media folders whose match scan --auto wasn't sure about, with the closest candidate, if any, and why it wasn't accepted.
+To resolve an item, run scan in its folder, with --title and --year if needed. Writing a match removes the folder from the queue.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		items, err := localConfig.DBH.ReviewQueue()
+		if err != nil {
+			log.Fatalln(" Couldn't read the review queue: ", err)
+		}
+		if len(items) == 0 {
+			fmt.Println("∅ Nothing to review.")
+			return
+		}
+		for _, item := range items {
+			fmt.Printf("? «%v» (%v) @ %v\n", item.Title, item.Year, item.Path)
+			if item.Candidate.ID == 0 {
+				fmt.Println("  candidate: none")
+			} else {
+				fmt.Printf("  candidate: %v\n", item.Candidate)
+			}
+			fmt.Printf("  reason:    %v\n", item.Reason)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+}
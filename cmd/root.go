@@ -1,12 +1,15 @@
 package cmd
 
 import (
-	"fmt"
+	"cmp"
+	"context"
 	"log"
 	"os"
+	"os/signal"
 	"time"
 
 	"github.com/JeanLeonHenry/mymedia/config"
+	"github.com/JeanLeonHenry/mymedia/internal/api"
 	"github.com/spf13/cobra"
 )
 
@@ -23,27 +26,79 @@ var rootCmd = &cobra.Command{
 var localConfig *config.Config
 var debug bool
 
+// language and region override the MYMEDIA_LANGUAGE and MYMEDIA_REGION settings of the config, if set.
+var language, region string
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Interrupting cancels the context of the running command.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
-func init() {
+// loadConfig reads the config file, it runs before any command.
+// The api credentials are only checked by the commands going online, see newProviders.
+func loadConfig() {
 	localConfig = config.New()
-	localConfig.Check()
-	msg := fmt.Sprintf("Was config valid ? %v\nConfig was : %+v", localConfig.IsValid, localConfig)
-	if !localConfig.IsValid {
-		log.Fatal(msg)
+	if language != "" || region != "" {
+		localConfig.SetLanguage(cmp.Or(language, localConfig.Language), region)
+	}
+}
+
+// newProviders checks the api credentials of the config and returns its metadata providers, exiting if they're missing.
+func newProviders() []api.MetadataProvider {
+	if err := localConfig.Check(); err != nil {
+		log.Fatalf(" Invalid config: %v\n", err)
 	}
+	providers, err := localConfig.NewProviders()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return providers
+}
+
+// requesters returns the Requesters of the api clients among providers, to change their settings.
+func requesters(providers []api.MetadataProvider) []*api.Requester {
+	var rs []*api.Requester
+	for _, p := range providers {
+		switch p := p.(type) {
+		case *api.TMDBClient:
+			rs = append(rs, &p.Requester)
+		case *api.OMDbClient:
+			rs = append(rs, &p.Requester)
+		}
+	}
+	return rs
+}
+
+// setProvidersLogger makes the api clients among providers log to l, and returns a func restoring their loggers.
+func setProvidersLogger(providers []api.MetadataProvider, l api.Logger) (restore func()) {
+	rs := requesters(providers)
+	old := make([]api.Logger, len(rs))
+	for i, r := range rs {
+		old[i], r.Logger = r.Logger, l
+	}
+	return func() {
+		for i, r := range rs {
+			r.Logger = old[i]
+		}
+	}
+}
+
+func init() {
+	cobra.OnInitialize(loadConfig)
 	// NOTE: use viper for better config handling ?
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "add extra logging")
+	rootCmd.PersistentFlags().StringVar(&language, "language", "", "language of the titles and overviews from TMDB.org, e.g. fr or fr-FR (default: MYMEDIA_LANGUAGE)")
+	rootCmd.PersistentFlags().StringVar(&region, "region", "", "region of the release dates from TMDB.org, e.g. FR (default: MYMEDIA_REGION, or the region of the language)")
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mymedia.yaml)")
 
 	// Cobra also supports local flags, which will only run
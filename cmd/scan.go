@@ -1,17 +1,24 @@
 package cmd
 
 import (
+	"bytes"
 	"cmp"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
-	"path"
+	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/JeanLeonHenry/mymedia/internal/medianame"
+	"github.com/JeanLeonHenry/mymedia/internal/nfo"
 	"github.com/JeanLeonHenry/mymedia/internal/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
@@ -21,7 +28,80 @@ const validationErrorMessage = `Field      %20v
 Failed     %20v =%v
 Got        %20v (type %v, kind %v)` + "\n"
 
-func parseArgs(cmd *cobra.Command) (string, int, int) {
+// isWrongYear reports whether year is before the invention of cinema or later than 10y in the future.
+func isWrongYear(year int) bool { return !medianame.IsValidYear(year) }
+
+// errNotMediaFolder is returned by readMediaFolder for folders that don't tell their media.
+var errNotMediaFolder = errors.New("found no id, nor title and year, in name, expected e.g. 'TITLE (YEAR)' or 'TITLE {tmdb-ID}'")
+
+// readMediaFolder reads the media info of the folder at dir from its name, see medianame.Parse,
+// from its Kodi NFO file and from its medianame.SidecarFile if it has them.
+// The ids of the sidecar take precedence over those of the NFO file, which take precedence over those of the name.
+// The title and year of the NFO file are used when the name doesn't give them.
+// The folder must be pinned to an id or must give a title and a year.
+func readMediaFolder(dir string) (mediaFolder, error) {
+	// A name without title may still give an id.
+	info, _ := medianame.Parse(filepath.Base(dir))
+	f := mediaFolder{Path: dir, Title: info.Title, Year: info.Year, Season: info.Season, TMDBID: info.TMDBID, IMDbID: info.IMDbID}
+	if err := f.readNFO(); err != nil {
+		return f, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, medianame.SidecarFile))
+	if err == nil {
+		pin, err := medianame.ParseSidecar(string(data))
+		if err != nil {
+			return f, err
+		}
+		f.TMDBID, f.TMDBType, f.IMDbID = pin.TMDBID, pin.TMDBType, pin.IMDbID
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	if !f.isPinned() && (f.Title == "" || f.Year == 0) {
+		return f, errNotMediaFolder
+	}
+	return f, nil
+}
+
+// readNFO reads the NFO file of f, if it has one, see nfo.Find.
+// An NFO file holding only a TMDB.org or IMDb url, as Kodi allows, pins f like a medianame.SidecarFile.
+// Other files that aren't NFO documents, like the .nfo of releases, are ignored.
+func (f *mediaFolder) readNFO() error {
+	path, err := nfo.Find(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil || path == "" {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	n, err := nfo.Read(bytes.NewReader(data))
+	if err != nil {
+		if pin, err := medianame.ParseSidecar(string(data)); err == nil {
+			f.TMDBID, f.TMDBType, f.IMDbID = pin.TMDBID, pin.TMDBType, pin.IMDbID
+		}
+		return nil
+	}
+	f.NFO = path
+	m := n.Media()
+	if m.ID != 0 || m.IMDbID != "" {
+		f.TMDBID, f.TMDBType, f.IMDbID = m.ID, "", m.IMDbID
+		if m.ID != 0 {
+			f.TMDBType = m.MediaType
+		}
+	}
+	if f.Title == "" {
+		f.Title = m.GetTitle()
+	}
+	if f.Year == 0 {
+		f.Year = m.GetYear()
+	}
+	return nil
+}
+
+// parseArgs reads the media folder at cwd from the title and year flags, or from cwd if they aren't set.
+func parseArgs(cmd *cobra.Command, cwd string) (mediaFolder, int) {
 	title, err := cmd.Flags().GetString("title")
 	if err != nil {
 		log.Fatalln(" Couldn't read title flag from config")
@@ -30,32 +110,18 @@ func parseArgs(cmd *cobra.Command) (string, int, int) {
 	if err != nil {
 		log.Fatalln(" Couldn't read year flag from config")
 	}
-	// we assume a year before the invention of cinema or later than 10y in the future is wrong.
-	isWrongYear := func(year int) bool { return year <= 1800 || year >= time.Now().Year()+10 }
-	if title == "" || isWrongYear(year) {
-		cwd, err := os.Getwd()
-		if err != nil {
-			log.Fatalln(" Wrong args: title is empty or year is wrong and I can't get the cwd")
-		}
-		fmt.Println("Reading info from current dir name")
-		basePath := path.Base(cwd)
-		fields := strings.Fields(basePath)
-		if len(fields) < 2 {
-			log.Fatalln(" Cwd name is badly formatted, must be 'TITLE (YEAR)'")
-		}
-		title = strings.Join(fields[:len(fields)-1], " ")
-		lastField := fields[len(fields)-1]
-		if currentYear := time.Now().Year(); len(lastField) <= 2 || len(lastField)-2 != len(strconv.Itoa(currentYear)) {
-			log.Fatalf(" Year has a wrong amount of digits, its %v and you gave %v\n", currentYear, lastField)
-		}
-		yearString := lastField[1 : len(lastField)-1]
-		year, err = strconv.Atoi(yearString)
-		if err != nil {
-			log.Fatalln(" Cwd name is badly formatted, must be 'TITLE (YEAR)'")
-		} else if isWrongYear(year) {
-			log.Fatalf(" Year must be between %v and %v\n", 1800, time.Now().Year()+10)
-		}
+	if title != "" && !isWrongYear(year) {
+		return mediaFolder{Path: cwd, Title: title, Year: year}, parseTolerance(cmd)
+	}
+	fmt.Println("Reading info from current dir name")
+	f, err := readMediaFolder(cwd)
+	if err != nil {
+		log.Fatalf(" Cwd %v\n", err)
 	}
+	return f, parseTolerance(cmd)
+}
+
+func parseTolerance(cmd *cobra.Command) int {
 	tolerance, err := cmd.Flags().GetInt("tolerance")
 	if err != nil {
 		log.Fatalln(" Couldn't read tolerance flag")
@@ -64,7 +130,7 @@ func parseArgs(cmd *cobra.Command) (string, int, int) {
 		tolerance = localConfig.DefaultTolerance
 		log.Printf(" Tolerance should be between 0 and 5 inclusive. Using %v\n", tolerance)
 	}
-	return title, year, tolerance
+	return tolerance
 }
 
 // findYearMatch finds the first element of media whose year (given by GetYear()) is minimum.
@@ -106,66 +172,951 @@ func validateResults(validate *validator.Validate, results []api.Media) (validRe
 	return
 }
 
+type scanStatus int
+
+const (
+	scanMatched scanStatus = iota
+	scanSkipped
+	scanQueued
+	scanFailed
+)
+
+// scanBatchSize is how many folders are written to DB per transaction in a recursive scan.
+const scanBatchSize = 50
+
+// scanner runs the scan pipeline.
+type scanner struct {
+	// providers are searched in turn, the primary one first, see search.
+	providers []api.MetadataProvider
+	dbh       *db.DBHandler
+	tolerance int
+	// policy is asked before going online when the media is already in the DB, and before writing.
+	policy acceptPolicy
+	// jobs is how many folders a recursive scan looks up concurrently.
+	jobs int
+	// dryRun plans the writes instead of doing them, see scanPlan.
+	dryRun bool
+	// offline builds the media from the NFO files of the folders instead of looking them up, see lookupOffline.
+	offline bool
+	out     api.Logger
+	// caches keep the responses of the lookups of scanFolders until writeResults saves them, see bufferCaches.
+	caches []*db.BufferedCache
+}
+
+func (s *scanner) printf(format string, v ...any) {
+	s.out.Printf(format, v...)
+}
+
+// scanResult is the outcome of looking up a folder.
+// With status scanMatched, media is yet to be written to DB, with scanQueued, it's yet to be queued for review.
+// reason tells why a folder is queued or skipped.
+type scanResult struct {
+	index  int
+	folder mediaFolder
+	status scanStatus
+	media  api.Media
+	// existing is the DB record found for the folder or its match, if any.
+	existing *db.Record
+	// provider is where media comes from, details and credits are fetched from it too.
+	provider api.MetadataProvider
+	reason   string
+	err      error
+	// seasons are the seasons of a TV show with episode files, found in episodeFiles.
+	seasons      []api.Season
+	episodeFiles map[episodeKey]string
+}
+
+// checkDB prints the DB record matching title and year, if there's one.
+func (s *scanner) checkDB(title string, year int) (*db.Record, error) {
+	record, found, err := s.dbh.FindMedia(title, year, s.tolerance)
+	if err != nil || !found {
+		return nil, err
+	}
+	out := record.String()
+	if debug {
+		out = record.Dump()
+	}
+	s.printf("✓ Found %v in DB.\n", out)
+	return &record, nil
+}
+
+// lookupFolder runs the lookup and match steps of the pipeline for a single media folder,
+// and fetches the details of the match if it's to be written.
+func (s *scanner) lookupFolder(ctx context.Context, f mediaFolder) (r scanResult) {
+	r.folder = f
+	fail := func(err error) scanResult {
+		r.status, r.err = scanFailed, err
+		return r
+	}
+	title, year := f.Title, f.Year
+	/*
+		PLAN
+		1 check db if we have a match: ask if we keep that data (skip) or replace it
+		2 poll api, get results, validate them
+		3 find a reasonnable match in the results
+		4 check db before writing the match if policy accepts, queue it for review if unsure
+	*/
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+	// 1
+	if title != "" {
+		existing, err := s.checkDB(title, year)
+		if err != nil {
+			return fail(fmt.Errorf("DB query error: %w", err))
+		}
+		r.existing = existing
+		if existing != nil && !s.policy.confirm("Proceed to online lookup?") {
+			r.status, r.reason = scanSkipped, "already in DB"
+			return r
+		}
+	}
+	// 2
+	if s.offline {
+		return s.lookupOffline(r)
+	}
+	if f.isPinned() {
+		return s.lookupPinned(ctx, r)
+	}
+	// 2, 3
+	media, validResults, ok, err := s.search(ctx, &r)
+	if err != nil {
+		return fail(err)
+	}
+	if len(validResults) == 0 {
+		s.printf("∅ Found no match for «%v» (%v).\n", title, year)
+		r.status, r.reason = scanSkipped, "no match on "+s.providerNames()
+		if s.policy.mode == acceptAuto {
+			// Left for review, the folder name may be wrong.
+			r.status, r.reason = scanQueued, "no results on "+s.providerNames()
+		}
+		return r
+	}
+	r.media = media
+	if !ok {
+		out := media.String()
+		if debug {
+			out = media.Dump()
+		}
+		s.printf("∅ Found no match for «%v» (%v).\nClosest match was : %+v\n", title, year, out)
+		if s.policy.mode != acceptAuto {
+			r.status, r.reason = scanSkipped, fmt.Sprintf("closest match year %v is not within %v of %v", media.GetYear(), s.tolerance, year)
+			return r
+		}
+	} else {
+		out := media.String()
+		if debug {
+			out = media.Dump()
+		}
+		s.printf("✓ Found %v match for «%v» (%v): %v\n", r.provider.Name(), title, year, out)
+		// 4
+		existing, err := s.checkDB(media.GetTitle(), media.GetYear())
+		if err != nil {
+			return fail(fmt.Errorf("DB query error: %w", err))
+		}
+		if existing != nil {
+			r.existing = existing
+		}
+	}
+	switch d, reason := s.policy.decideMatch(title, year, s.tolerance, media, validResults); d {
+	case decisionReject:
+		r.status, r.reason = scanSkipped, "match not accepted"
+		return r
+	case decisionReview:
+		r.status, r.reason = scanQueued, reason
+		return r
+	}
+	return s.fetchDetails(ctx, r)
+}
+
+// search searches the title of r on the providers of s in turn, until one of them has a result within tolerance of the year of r.
+// It returns that match and the valid results of its provider, with ok set, or else the closest result of the first provider with results.
+// r.provider is set to the provider of the returned results. A provider failing fails the search.
+func (s *scanner) search(ctx context.Context, r *scanResult) (media api.Media, validResults []api.Media, ok bool, err error) {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	for _, p := range s.providers {
+		results, err := p.Search(ctx, r.folder.Title)
+		if err != nil {
+			return media, nil, false, fmt.Errorf("searching %v: %w", p.Name(), err)
+		}
+		valid := validateResults(validate, results)
+		if len(valid) == 0 {
+			continue
+		}
+		match, found := findYearMatch(valid, r.folder.Year, s.tolerance)
+		if found || validResults == nil {
+			media, validResults, r.provider = match, valid, p
+		}
+		if found {
+			return media, validResults, true, nil
+		}
+	}
+	return media, validResults, false, nil
+}
+
+// providerNames names the providers of s, for messages.
+func (s *scanner) providerNames() string {
+	var names []string
+	for _, p := range s.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, " nor ")
+}
+
+// lookupPinned replaces the search and match steps of the pipeline for a folder pinned to an id:
+// the media is fetched by id, leaving no doubt about the match.
+func (s *scanner) lookupPinned(ctx context.Context, r scanResult) scanResult {
+	fail := func(err error) scanResult {
+		r.status, r.err = scanFailed, err
+		return r
+	}
+	media, provider, matches, err := s.fetchPinned(ctx, r.folder)
+	if err != nil {
+		return fail(fmt.Errorf("getting media pinned by %v: %w", r.folder.pin(), err))
+	}
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(media); err != nil {
+		return fail(fmt.Errorf("media pinned by %v is incomplete: %w", r.folder.pin(), err))
+	}
+	r.media, r.provider = media, provider
+	out := media.String()
+	if debug {
+		out = media.Dump()
+	}
+	if !matches {
+		s.printf("? Found %v media pinned by %v, but it doesn't match «%v» (%v): %v\n", provider.Name(), r.folder.pin(), r.folder.Title, r.folder.Year, out)
+		r.status, r.reason = scanQueued, fmt.Sprintf("%v gives «%v» (%v), not the media of the folder name", r.folder.pin(), media.GetTitle(), media.GetYear())
+		return r
+	}
+	s.printf("✓ Found %v media pinned by %v: %v\n", provider.Name(), r.folder.pin(), out)
+	existing, err := s.checkDB(media.GetTitle(), media.GetYear())
+	if err != nil {
+		return fail(fmt.Errorf("DB query error: %w", err))
+	}
+	if existing != nil {
+		r.existing = existing
+	}
+	if s.policy.decidePinned() == decisionReject {
+		r.status, r.reason = scanSkipped, "match not accepted"
+		return r
+	}
+	return s.fetchDetails(ctx, r)
+}
+
+// lookupOffline replaces the lookup steps of the pipeline when offline: the media is read from the NFO file of the folder,
+// which must give its TMDB.org id, and its poster from the folder. It has no credits and no episodes.
+func (s *scanner) lookupOffline(r scanResult) scanResult {
+	fail := func(err error) scanResult {
+		r.status, r.err = scanFailed, err
+		return r
+	}
+	if r.folder.NFO == "" {
+		r.status, r.reason = scanSkipped, "no NFO file to read offline"
+		return r
+	}
+	n, err := nfo.ReadFile(r.folder.NFO)
+	if err != nil {
+		return fail(fmt.Errorf("reading NFO file: %w", err))
+	}
+	media := n.Media()
+	if media.ID == 0 {
+		r.status, r.reason = scanSkipped, "NFO file has no TMDB id"
+		return r
+	}
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(media); err != nil {
+		return fail(fmt.Errorf("media of %v is incomplete: %w", r.folder.NFO, err))
+	}
+	if _, err := readLocalPoster(&media, r.folder.Path); err != nil {
+		return fail(fmt.Errorf("reading poster: %w", err))
+	}
+	r.media = media
+	out := media.String()
+	if debug {
+		out = media.Dump()
+	}
+	s.printf("✓ Read %v: %v\n", r.folder.NFO, out)
+	existing, err := s.checkDB(media.GetTitle(), media.GetYear())
+	if err != nil {
+		return fail(fmt.Errorf("DB query error: %w", err))
+	}
+	if existing != nil {
+		r.existing = existing
+	}
+	if s.policy.decidePinned() == decisionReject {
+		r.status, r.reason = scanSkipped, "match not accepted"
+		return r
+	}
+	r.status = scanMatched
+	return r
+}
+
+// readLocalPoster reads the poster image of the media folder at dir into m, see nfo.FindPoster, and reports whether there's one.
+func readLocalPoster(m *api.Media, dir string) (bool, error) {
+	path := nfo.FindPoster(dir)
+	if path == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	m.PosterData = data
+	return true, nil
+}
+
+// fetchPinned fetches the media f is pinned to from the first provider of s that knows it, and returns that provider.
+// A TMDB id of unknown media type is tried as a TV show's first if f names a season or holds episode files, as a movie's otherwise.
+// The media of the other type is tried if the first isn't found or doesn't match f, see pinMatches,
+// matches is false if neither matches: movies and TV shows have ids of their own.
+// If no provider knows the media, the error of the first one is returned.
+func (s *scanner) fetchPinned(ctx context.Context, f mediaFolder) (media api.Media, provider api.MetadataProvider, matches bool, err error) {
+	pin := api.Media{ID: f.TMDBID, MediaType: f.TMDBType, IMDbID: f.IMDbID}
+	if pin.ID == 0 || pin.MediaType != "" {
+		media, provider, err = s.fetchPinnedMedia(ctx, pin)
+		return media, provider, err == nil, err
+	}
+	tvFirst := f.Season > 0
+	if !tvFirst {
+		files, err := findEpisodeFiles(f.Path)
+		tvFirst = err == nil && len(files) > 0
+	}
+	types := []string{api.MediaTypeMovie, api.MediaTypeTV}
+	if tvFirst {
+		types = []string{api.MediaTypeTV, api.MediaTypeMovie}
+	}
+	var firstErr error
+	for _, mediaType := range types {
+		pin.MediaType = mediaType
+		m, p, err := s.fetchPinnedMedia(ctx, pin)
+		switch {
+		case err == nil && pinMatches(m, f, s.tolerance):
+			return m, p, true, nil
+		case err == nil && provider == nil:
+			media, provider = m, p
+		case err != nil && firstErr == nil:
+			firstErr = err
+		}
+		if err != nil && !errors.Is(err, api.ErrNotFound) {
+			return api.Media{}, nil, false, err
+		}
+	}
+	if provider != nil {
+		return media, provider, false, nil
+	}
+	return api.Media{}, nil, false, firstErr
+}
+
+// pinMatches tells whether m, fetched by a TMDB id of unknown media type, is the media f is named after:
+// its title or original title is the title of f once both are sanitized like folder names, and its year is within tolerance of the year of f.
+// Missing title or year in f match anything.
+func pinMatches(m api.Media, f mediaFolder, tolerance int) bool {
+	if f.Year != 0 && utils.Abs(m.GetYear()-f.Year) > tolerance {
+		return false
+	}
+	if f.Title == "" {
+		return true
+	}
+	title := medianame.Sanitize(f.Title)
+	return strings.EqualFold(medianame.Sanitize(m.GetTitle()), title) || strings.EqualFold(medianame.Sanitize(m.GetOriginalTitle()), title)
+}
+
+// fetchPinnedMedia fetches the media pin from the first provider of s that knows it, and returns that provider.
+// If no provider knows the media, the error of the first one is returned.
+func (s *scanner) fetchPinnedMedia(ctx context.Context, pin api.Media) (api.Media, api.MetadataProvider, error) {
+	var firstErr error
+	for _, p := range s.providers {
+		media, err := p.Details(ctx, pin)
+		if err == nil {
+			return media, p, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !errors.Is(err, api.ErrNotFound) {
+			break
+		}
+	}
+	return api.Media{}, nil, firstErr
+}
+
+// fetchDetails downloads the details, credits and poster of the media of r from r.provider,
+// and the episodes of a TV show if the provider knows them, to be written.
+// The poster image of the folder is used instead of the provider's, if it has one.
+func (s *scanner) fetchDetails(ctx context.Context, r scanResult) scanResult {
+	// Searches and finds don't give all the details.
+	var show api.Show
+	var err error
+	shows, hasShows := r.provider.(api.ShowProvider)
+	if r.media.MediaType == api.MediaTypeTV && hasShows {
+		show, err = shows.GetShow(ctx, r.media.ID)
+		r.media = show.Media
+	} else {
+		r.media, err = r.provider.Details(ctx, r.media)
+	}
+	if err != nil {
+		r.status, r.err = scanFailed, fmt.Errorf("getting details: %w", err)
+		return r
+	}
+	if err := r.provider.Credits(ctx, &r.media); err != nil {
+		r.status, r.err = scanFailed, fmt.Errorf("getting credits: %w", err)
+		return r
+	}
+	if found, err := readLocalPoster(&r.media, r.folder.Path); err != nil {
+		r.status, r.err = scanFailed, fmt.Errorf("reading poster: %w", err)
+		return r
+	} else if !found {
+		if err := r.provider.Artwork(ctx, &r.media); err != nil {
+			r.status, r.err = scanFailed, fmt.Errorf("getting poster: %w", err)
+			return r
+		}
+	}
+	if r.media.MediaType == api.MediaTypeTV && hasShows {
+		if err := s.fetchEpisodes(ctx, &r, shows, show); err != nil {
+			r.status, r.err = scanFailed, fmt.Errorf("getting episodes: %w", err)
+			return r
+		}
+	}
+	r.status = scanMatched
+	return r
+}
+
+// episodeKey identifies an episode of a TV show.
+type episodeKey struct {
+	season, episode int
+}
+
+// findEpisodeFiles walks the folder of a TV show at dir and returns the video files named after an episode, e.g. 'The Expanse S01E02.mkv'.
+// A folder that doesn't exist has no episode files.
+func findEpisodeFiles(dir string) (map[episodeKey]string, error) {
+	files := make(map[episodeKey]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !medianame.IsVideoFile(d.Name()) {
+			return nil
+		}
+		info, _ := medianame.Parse(d.Name())
+		if info.Episode == 0 {
+			return nil
+		}
+		key := episodeKey{info.Season, info.Episode}
+		if _, ok := files[key]; !ok {
+			files[key] = p
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return files, err
+}
+
+// fetchEpisodes fetches the seasons of show, the TV show of r, that have episode files in its folder,
+// and the stills of the episodes that have a file, from p.
+func (s *scanner) fetchEpisodes(ctx context.Context, r *scanResult, p api.ShowProvider, show api.Show) error {
+	files, err := findEpisodeFiles(r.folder.Path)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	withFiles := make(map[int]bool)
+	for key := range files {
+		withFiles[key.season] = true
+	}
+	found := make(map[episodeKey]bool)
+	for _, summary := range show.Seasons {
+		if !withFiles[summary.SeasonNumber] {
+			continue
+		}
+		season, err := p.GetSeason(ctx, show.ID, summary.SeasonNumber)
+		if err != nil {
+			return err
+		}
+		for i := range season.Episodes {
+			e := &season.Episodes[i]
+			key := episodeKey{e.SeasonNumber, e.EpisodeNumber}
+			if _, ok := files[key]; !ok {
+				continue
+			}
+			found[key] = true
+			if err := p.GetStill(ctx, e); err != nil {
+				return fmt.Errorf("getting still of %v: %w", e, err)
+			}
+		}
+		r.seasons = append(r.seasons, season)
+	}
+	var missing []string
+	for key, path := range files {
+		if !found[key] {
+			missing = append(missing, path)
+		}
+	}
+	slices.Sort(missing)
+	for _, path := range missing {
+		s.printf(" Found no TMDB.org episode for %v\n", path)
+	}
+	s.printf("✓ Found %v episode files of %v\n", len(found), r.media)
+	r.episodeFiles = files
+	return nil
+}
+
+// mediaWriter writes scan results, it's implemented by *db.DBHandler and *db.Batch.
+type mediaWriter interface {
+	WriteToDB(media api.Media, path string) (sql.Result, error)
+	WriteCredits(mediaID int, credits []api.Credit) error
+	WriteSeason(showID int, season api.Season) error
+	WriteEpisode(showID int, episode api.Episode, path string) error
+	QueueForReview(path string, title string, year int, candidate api.Media, reason string) error
+	Unqueue(path string) error
+}
+
+// writeResult writes the media of r to w, or queues it for review, depending on r.status.
+func writeResult(w mediaWriter, r scanResult) error {
+	switch r.status {
+	case scanMatched:
+		if _, err := w.WriteToDB(r.media, r.folder.Path); err != nil {
+			return fmt.Errorf("DB write error: %w", err)
+		}
+		if err := w.WriteCredits(r.media.ID, r.media.Credits); err != nil {
+			return fmt.Errorf("DB write error: %w", err)
+		}
+		for _, season := range r.seasons {
+			if err := w.WriteSeason(r.media.ID, season); err != nil {
+				return fmt.Errorf("DB write error: %w", err)
+			}
+			for _, e := range season.Episodes {
+				if err := w.WriteEpisode(r.media.ID, e, r.episodeFiles[episodeKey{e.SeasonNumber, e.EpisodeNumber}]); err != nil {
+					return fmt.Errorf("DB write error: %w", err)
+				}
+			}
+		}
+		if err := w.Unqueue(r.folder.Path); err != nil {
+			return fmt.Errorf("DB review queue error: %w", err)
+		}
+	case scanQueued:
+		if err := w.QueueForReview(r.folder.Path, r.folder.Title, r.folder.Year, r.media, r.reason); err != nil {
+			return fmt.Errorf("DB review queue error: %w", err)
+		}
+	}
+	return nil
+}
+
+// reportWritten prints what was written for r.
+func (s *scanner) reportWritten(r scanResult) {
+	switch r.status {
+	case scanMatched:
+		s.printf("✓ Wrote to DB: %v\n", r.media)
+		if debug {
+			s.printf("Tried writing/Wrote: %v\n", r.media.Dump())
+		}
+	case scanQueued:
+		s.printf("? Queued «%v» (%v) for review: %v\n", r.folder.Title, r.folder.Year, r.reason)
+	}
+}
+
+// writeResults writes results, and the responses in s.caches, in a single transaction: if one of the results fails, none is written.
+func (s *scanner) writeResults(results []scanResult) error {
+	tx, err := s.dbh.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range results {
+		if err := writeResult(tx, r); err != nil {
+			return fmt.Errorf("%v: %w", r.folder.Path, err)
+		}
+	}
+	for _, c := range s.caches {
+		if err := c.Flush(tx); err != nil {
+			s.printf(" Couldn't write the response cache: %v\n", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// scanFolder runs the lookup, match and write pipeline for a single media folder.
+// Its writes are a single transaction, a failure leaves the DB as it was.
+func (s *scanner) scanFolder(ctx context.Context, f mediaFolder) (scanStatus, error) {
+	r := s.lookupFolder(ctx, f)
+	if err := s.writeResults([]scanResult{r}); err != nil {
+		return scanFailed, err
+	}
+	s.reportWritten(r)
+	return r.status, r.err
+}
+
+// mediaFolder is a folder holding a media, see readMediaFolder.
+type mediaFolder struct {
+	Path   string
+	Title  string
+	Year   int
+	Season int
+	// TMDBID, of type TMDBType if known, or IMDbID pin the folder to its media.
+	TMDBID   int
+	TMDBType string
+	IMDbID   string
+	// NFO is the path of the Kodi NFO file of the folder, if it has one, see readNFO.
+	NFO string
+}
+
+// isPinned reports whether f names the id of its media.
+func (f mediaFolder) isPinned() bool { return f.TMDBID != 0 || f.IMDbID != "" }
+
+// pin describes the id f is pinned to.
+func (f mediaFolder) pin() string {
+	switch {
+	case f.TMDBID != 0 && f.TMDBType != "":
+		return fmt.Sprintf("TMDB id %v/%v", f.TMDBType, f.TMDBID)
+	case f.TMDBID != 0:
+		return fmt.Sprintf("TMDB id %v", f.TMDBID)
+	}
+	return "IMDb id " + f.IMDbID
+}
+
+// findMediaFolders walks root and returns every media folder, see readMediaFolder.
+// Media folders aren't descended into, hidden folders are ignored.
+func findMediaFolders(root string) ([]mediaFolder, error) {
+	var folders []mediaFolder
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			log.Printf(" Couldn't read %v: %v\n", p, err)
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != root && strings.HasPrefix(name, ".") {
+			return fs.SkipDir
+		}
+		f, err := readMediaFolder(p)
+		if errors.Is(err, errNotMediaFolder) {
+			return nil
+		} else if err != nil {
+			log.Printf(" Couldn't read %v: %v\n", p, err)
+			return nil
+		}
+		folders = append(folders, f)
+		return fs.SkipDir
+	})
+	return folders, err
+}
+
+// scanFailure is a folder whose scan ended with an error.
+type scanFailure struct {
+	Path string
+	Err  error
+}
+
+// scanSummary records the folders of a recursive scan by outcome.
+type scanSummary struct {
+	Matched []string
+	Skipped []string
+	Queued  []string
+	Failed  []scanFailure
+	// Plans holds what a dry run would have done, by folder.
+	Plans []scanPlan
+}
+
+func (s scanSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scanned %v folders: %v matched, %v skipped, %v queued for review, %v failed\n",
+		len(s.Matched)+len(s.Skipped)+len(s.Queued)+len(s.Failed), len(s.Matched), len(s.Skipped), len(s.Queued), len(s.Failed))
+	for _, f := range s.Failed {
+		fmt.Fprintf(&b, " %v: %v\n", f.Path, f.Err)
+	}
+	return b.String()
+}
+
+func (s *scanner) scanRecursive(ctx context.Context, root string) scanSummary {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		log.Fatalln(" Couldn't resolve library root: ", err)
+	}
+	folders, err := findMediaFolders(root)
+	if err != nil {
+		log.Fatalln(" Couldn't walk library root: ", err)
+	}
+	s.printf("Found %v media folders under %v\n", len(folders), root)
+	return s.scanFolders(ctx, folders)
+}
+
+// scanFolders looks folders up with s.jobs workers, and writes the results from the calling goroutine,
+// in the order of folders and in batched transactions.
+// Unless s.policy prompts the user, progress is shown on a spinner.
+func (s *scanner) scanFolders(ctx context.Context, folders []mediaFolder) scanSummary {
+	var p *progress
+	if s.policy.mode != acceptAsk {
+		p = newProgress(len(folders), s.out)
+		out := s.out
+		s.out = p
+		restore := setProvidersLogger(s.providers, p)
+		defer func() { s.out = out; restore() }()
+		p.Start()
+		defer p.Stop()
+	}
+	defer s.bufferCaches()()
+	indices := make(chan int)
+	go func() {
+		defer close(indices)
+		for i := range folders {
+			indices <- i
+		}
+	}()
+	results := make(chan scanResult)
+	var wg sync.WaitGroup
+	for range max(s.jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if p == nil {
+					s.printf("[%v/%v] %v\n", i+1, len(folders), folders[i].Path)
+				}
+				r := s.lookupFolder(ctx, folders[i])
+				r.index = i
+				if p != nil {
+					p.Advance()
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var summary scanSummary
+	pending := make(map[int]scanResult)
+	var batch []scanResult
+	next := 0
+	for r := range results {
+		pending[r.index] = r
+		// Results come in any order, commit them in folder order.
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			batch = append(batch, r)
+			if len(batch) == scanBatchSize {
+				s.commit(batch, &summary)
+				batch = nil
+			}
+		}
+	}
+	s.commit(batch, &summary)
+	return summary
+}
+
+// bufferCaches makes the api clients among s.providers keep the responses they cache in s.caches,
+// so that the workers of scanFolders don't write to the DB, and returns a func restoring their caches.
+func (s *scanner) bufferCaches() (restore func()) {
+	rs := requesters(s.providers)
+	caches := make([]api.Cache, len(rs))
+	for i, r := range rs {
+		caches[i] = r.Cache
+		if c, ok := r.Cache.(db.ResponseCache); ok {
+			b := c.Buffered()
+			r.Cache = b
+			s.caches = append(s.caches, b)
+		}
+	}
+	return func() {
+		for i, r := range rs {
+			r.Cache = caches[i]
+		}
+		s.caches = nil
+	}
+}
+
+// commit writes batch in a single transaction and adds its results to summary.
+// If the transaction fails, the results to write are counted as failed.
+// On a dry run, nothing is written and the plans of batch are added to summary instead.
+func (s *scanner) commit(batch []scanResult, summary *scanSummary) {
+	if len(batch) == 0 {
+		return
+	}
+	if s.dryRun {
+		for _, r := range batch {
+			summary.Plans = append(summary.Plans, s.plan(r))
+		}
+		return
+	}
+	err := s.writeResults(batch)
+	for _, r := range batch {
+		if err != nil && (r.status == scanMatched || r.status == scanQueued) {
+			r.status, r.err = scanFailed, fmt.Errorf("DB batch write error: %w", err)
+		}
+		path := r.folder.Path
+		switch r.status {
+		case scanMatched:
+			s.reportWritten(r)
+			summary.Matched = append(summary.Matched, path)
+		case scanSkipped:
+			summary.Skipped = append(summary.Skipped, path)
+		case scanQueued:
+			s.reportWritten(r)
+			summary.Queued = append(summary.Queued, path)
+		case scanFailed:
+			s.printf(" %v: %v\n", path, r.err)
+			summary.Failed = append(summary.Failed, scanFailure{Path: path, Err: r.err})
+		}
+	}
+}
+
+// parseDryRun reads the dry-run and format flags.
+func parseDryRun(cmd *cobra.Command) (dryRun bool, format string) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		log.Fatalln(" Couldn't read dry-run flag from config")
+	}
+	format, err = cmd.Flags().GetString("format")
+	if err != nil {
+		log.Fatalln(" Couldn't read format flag from config")
+	}
+	if format != "text" && format != "json" {
+		log.Fatalf(" Unknown format %v, must be text or json\n", format)
+	}
+	if cmd.Flags().Changed("format") && !dryRun {
+		log.Fatalln(" --format is only used with --dry-run")
+	}
+	return dryRun, format
+}
+
+// printPlans prints the plans of a dry run to stdout in format.
+func printPlans(plans []scanPlan, format string) {
+	if format == "json" {
+		if err := writePlansJSON(os.Stdout, plans); err != nil {
+			log.Fatalln(" Couldn't print plans: ", err)
+		}
+		return
+	}
+	writePlansText(os.Stdout, plans)
+}
+
 // scanCmd represents the scan command
 var scanCmd = &cobra.Command{
-	Use:     "scan",
-	Short:   "Scans the current folder for media folders and update database",
-	Long:    `WIP`,
-	Example: ``,
+	Use:   "scan [root]",
+	Short: "Scans the current folder for media folders and update database",
+	Long: `Looks up the media in the current folder on TMDB.org and writes the match to the database.
+Title and year are read from the folder name: 'TITLE (YEAR)', 'TITLE [YEAR]' or release names like 'Title.2019.1080p.BluRay.x264' work.
+Folders named with an id, like 'TITLE {tmdb-ID}' or 'TITLE [imdbid-ttID]', or holding a .tmdb file, are fetched by id without searching.
+With --recursive, walks the library tree under root (default: current folder) and scans every folder whose name gives a title and a year, or an id.
+With --yes, --no or --auto, never prompts so it can run unattended. Matches --auto isn't sure about, and folders without results, are queued for review, see the review command.
+A folder's Kodi NFO file (movie.nfo, tvshow.nfo...) gives its id, title and year too, and a poster.jpg or folder.jpg in it is used as poster.
+With --offline, the database is built from the NFO files alone, no api token needed: folders without an NFO file giving a TMDB id are skipped.
+With --dry-run, nothing is written: the folders to insert, update or leave alone are printed instead, with a field-by-field diff for updates.
+A dry run doesn't prompt, it plans every write unless --no or --auto is set.`,
+	Example: `  mymedia scan --title "Alien" --year 1979
+  mymedia scan --recursive /mnt/films
+  mymedia scan --recursive --auto --jobs 8 /mnt/films
+  mymedia scan --recursive --offline --yes /mnt/films
+  mymedia scan --recursive --yes --dry-run --format json /mnt/films`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		/*
-			PLAN
-			1 get (title, year) from config
-			2 check db if we have a match: ask if we keep that data (quit) or replace it
-			3 poll api, get results, validate them
-			4 find a reasonnable match in the results
-			5 check db before writing the match if user accepts
-		*/
-		// 1
-		title, year, tolerance := parseArgs(cmd)
-		// 2
-		if localConfig.DBH.CheckDB(title, year, tolerance, debug) {
-			utils.AcceptOrQuit("Proceed to online lookup?")
-		}
-		// 3
-		response := api.ApiMultiSearch(title, localConfig.ApiReadToken)
-		validate := validator.New(validator.WithRequiredStructEnabled())
-		validResults := validateResults(validate, response.Results)
-		if len(validResults) == 0 {
-			fmt.Printf("∅ Found no match for «%v» (%v).\n", title, year)
-			return
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			log.Fatalln(" Couldn't read recursive flag from config")
 		}
-		// 4
-		media, ok := findYearMatch(validResults, year, tolerance)
-		if !ok {
-			out := media.String()
-			if debug {
-				out = media.Dump()
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			log.Fatalln(" Couldn't read offline flag from config")
+		}
+		// Offline, the providers are never used, so they need no credentials.
+		var providers []api.MetadataProvider
+		if !offline {
+			providers = newProviders()
+		}
+		refresh, err := cmd.Flags().GetBool("refresh")
+		if err != nil {
+			log.Fatalln(" Couldn't read refresh flag from config")
+		}
+		dryRun, format := parseDryRun(cmd)
+		out := log.New(os.Stdout, "", 0)
+		for _, r := range requesters(providers) {
+			r.Refresh = refresh
+			if dryRun && r.Cache != nil {
+				r.Cache = readOnlyCache{r.Cache}
 			}
-			fmt.Printf("∅ Found no match for «%v» (%v).\nClosest match was : %+v\n", title, year, out)
-			return
 		}
-		out := media.String()
-		if debug {
-			out = media.Dump()
+		if dryRun && format == "json" {
+			// Keep stdout for the plans.
+			out = log.New(os.Stderr, "", 0)
+			setProvidersLogger(providers, out)
 		}
-		fmt.Printf("✓ Found TMDB.org match for «%v» (%v): %v\n", title, year, out)
-		// 5
-		localConfig.DBH.CheckDB(media.GetTitle(), media.GetYear(), tolerance, debug)
-		utils.AcceptOrQuit("Write to DB ?")
-		media.GetDirector(localConfig.ApiReadToken)
-		media.GetPoster(localConfig.ApiKey)
-		if cwdPath, err := os.Getwd(); err != nil {
-			log.Fatalln(" Couldn't get current dir path")
-		} else {
-			_, err := localConfig.DBH.WriteToDB(media, cwdPath)
+		if recursive {
+			root := "."
+			if len(args) == 1 {
+				root = args[0]
+			}
+			jobs, err := cmd.Flags().GetInt("jobs")
 			if err != nil {
-				log.Fatalln(" DB write error: ", err)
+				log.Fatalln(" Couldn't read jobs flag from config")
 			}
-			fmt.Println("✓ Wrote to DB: ", media)
-			if debug {
-				fmt.Println("Tried writing/Wrote: ", media.Dump())
+			s := &scanner{
+				providers: providers,
+				dbh:       localConfig.DBH,
+				tolerance: parseTolerance(cmd),
+				policy:    newAcceptPolicy(cmd, utils.Accept).forDryRun(dryRun),
+				jobs:      jobs,
+				dryRun:    dryRun,
+				offline:   offline,
+				out:       out,
+			}
+			if jobs < 1 {
+				log.Fatalln(" jobs must be at least 1")
+			} else if jobs > 1 && s.policy.mode == acceptAsk {
+				log.Fatalln(" --jobs needs --yes, --no or --auto, prompts can't run concurrently")
+			}
+			summary := s.scanRecursive(cmd.Context(), root)
+			if dryRun {
+				printPlans(summary.Plans, format)
+				return
+			}
+			fmt.Print(summary)
+			if len(summary.Failed) > 0 {
+				os.Exit(1)
 			}
+			return
+		}
+		if len(args) > 0 {
+			log.Fatalln(" root is only used with --recursive")
+		}
+		cwdPath, err := os.Getwd()
+		if err != nil {
+			log.Fatalln(" Couldn't get current dir path")
+		}
+		f, tolerance := parseArgs(cmd, cwdPath)
+		s := &scanner{
+			providers: providers,
+			dbh:       localConfig.DBH,
+			tolerance: tolerance,
+			policy:    newAcceptPolicy(cmd, askQuitOnNo).forDryRun(dryRun),
+			offline:   offline,
+			out:       out,
+		}
+		if dryRun {
+			r := s.lookupFolder(cmd.Context(), f)
+			printPlans([]scanPlan{s.plan(r)}, format)
+			return
+		}
+		status, err := s.scanFolder(cmd.Context(), f)
+		if status == scanFailed {
+			log.Fatalln("", err)
 		}
 		if debug {
 			fmt.Println("-- DUMP --")
@@ -189,5 +1140,12 @@ func init() {
 	scanCmd.Flags().StringP("title", "t", "", "media title, case insensitive, will be read from cwd name if missing")
 	scanCmd.Flags().IntP("year", "y", 0, "media release year")
 	scanCmd.Flags().Int("tolerance", 2, "on lookup, result will be accepted if title match and year is within tolerance of result")
+	scanCmd.Flags().BoolP("recursive", "R", false, "walk the library tree under root and scan every folder named like 'TITLE (YEAR)'")
+	scanCmd.Flags().Bool("refresh", false, "ignore cached TMDB.org responses")
+	scanCmd.Flags().IntP("jobs", "j", 1, "with --recursive, how many folders to look up concurrently")
+	scanCmd.Flags().Bool("dry-run", false, "look up and match as usual, then print what would be written instead of writing it")
+	scanCmd.Flags().String("format", "text", "output format of --dry-run: text or json")
+	scanCmd.Flags().Bool("offline", false, "read the media from NFO files and local posters only, without going online")
+	addAcceptPolicyFlags(scanCmd)
 
 }
@@ -0,0 +1,618 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/api/apitest"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+)
+
+func TestReadMediaFolder(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string
+		// nfo is the content of a movie.nfo file.
+		nfo     string
+		want    mediaFolder
+		wantErr bool
+	}{
+		{name: "Alien (1979)", want: mediaFolder{Title: "Alien", Year: 1979}},
+		{name: "Alien.1979.1080p.BluRay.x264", want: mediaFolder{Title: "Alien", Year: 1979}},
+		{name: "Alien (1979) {tmdb-348}", want: mediaFolder{Title: "Alien", Year: 1979, TMDBID: 348}},
+		{name: "Heat [imdbid-tt0113277]", want: mediaFolder{Title: "Heat", IMDbID: "tt0113277"}},
+		{name: "The Expanse S01 {tmdb-63639}", want: mediaFolder{Title: "The Expanse", Season: 1, TMDBID: 63639}},
+		{name: "Heat", sidecar: "movie/949\n", want: mediaFolder{Title: "Heat", TMDBID: 949, TMDBType: "movie"}},
+		// The sidecar wins over the name.
+		{name: "Alien (1979) {tmdb-1}", sidecar: "tt0078748", want: mediaFolder{Title: "Alien", Year: 1979, IMDbID: "tt0078748"}},
+		{name: "Alien", nfo: `<movie><title>Alien</title><year>1979</year><uniqueid type="tmdb">348</uniqueid></movie>`,
+			want: mediaFolder{Title: "Alien", Year: 1979, TMDBID: 348, TMDBType: "movie", NFO: "movie.nfo"}},
+		{name: "The Expanse", nfo: `<tvshow><title>The Expanse</title><premiered>2015-12-14</premiered><tmdbid>63639</tmdbid></tvshow>`,
+			want: mediaFolder{Title: "The Expanse", Year: 2015, TMDBID: 63639, TMDBType: "tv", NFO: "movie.nfo"}},
+		{name: "Heat", nfo: "https://www.themoviedb.org/movie/949-heat\n", want: mediaFolder{Title: "Heat", TMDBID: 949, TMDBType: "movie"}},
+		// The sidecar wins over the NFO file, which wins over the name.
+		{name: "Alien {tmdb-1}", sidecar: "tt0078748", nfo: `<movie><year>1979</year><uniqueid type="tmdb">348</uniqueid></movie>`,
+			want: mediaFolder{Title: "Alien", Year: 1979, IMDbID: "tt0078748", NFO: "movie.nfo"}},
+		// The .nfo of a release isn't an NFO document.
+		{name: "Alien (1979)", nfo: "  ___ Release Group ___\n", want: mediaFolder{Title: "Alien", Year: 1979}},
+		{name: "Alien", wantErr: true},
+		{name: "Alien (79)", wantErr: true},
+		{name: "The Expanse S01", wantErr: true},
+		{name: "extras", wantErr: true},
+		{name: "Alien (1979)", sidecar: "Alien", wantErr: true},
+	}
+	for _, test := range tests {
+		dir := filepath.Join(t.TempDir(), test.name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if test.sidecar != "" {
+			if err := os.WriteFile(filepath.Join(dir, ".tmdb"), []byte(test.sidecar), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.nfo != "" {
+			if err := os.WriteFile(filepath.Join(dir, "movie.nfo"), []byte(test.nfo), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f, err := readMediaFolder(dir)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("readMediaFolder(%q) = %+v, want an error", test.name, f)
+			}
+			continue
+		}
+		test.want.Path = dir
+		if test.want.NFO != "" {
+			test.want.NFO = filepath.Join(dir, test.want.NFO)
+		}
+		if err != nil || f != test.want {
+			t.Errorf("readMediaFolder(%q) = %+v, %v, want %+v", test.name, f, err, test.want)
+		}
+	}
+}
+
+func TestFindYearMatch(t *testing.T) {
+	media := []api.Media{
+		{ID: 1, ReleaseDate: "1986-11-21"},
+		{ID: 2, ReleaseDate: "1995-12-15"},
+		{ID: 3, FirstAirDate: "2001-01-01"},
+	}
+	tests := []struct {
+		year, tolerance int
+		wantID          int
+		wantFound       bool
+	}{
+		{1995, 0, 2, true},
+		{1987, 1, 1, true},
+		{1990, 2, 1, false},
+		{2003, 2, 3, true},
+	}
+	for _, test := range tests {
+		result, found := findYearMatch(media, test.year, test.tolerance)
+		if result.ID != test.wantID || found != test.wantFound {
+			t.Errorf("findYearMatch(%v, %v) = %v, %v, want %v, %v", test.year, test.tolerance, result.ID, found, test.wantID, test.wantFound)
+		}
+	}
+}
+
+// newTestScanner returns a scanner of a fake TMDB.org writing to a new db, and that db.
+func newTestScanner(t *testing.T, mode acceptMode) (*scanner, *db.DBHandler) {
+	t.Helper()
+	dbh, err := db.Open(filepath.Join(t.TempDir(), "media.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbh.DB.Close() })
+	s := &scanner{
+		providers: []api.MetadataProvider{apitest.NewServer(t).TMDBClient()},
+		dbh:       dbh,
+		tolerance: 2,
+		policy: acceptPolicy{mode: mode, ask: func(prompt string) bool {
+			t.Fatalf("asked %q in non-interactive mode", prompt)
+			return false
+		}},
+		jobs: 1,
+		out:  log.New(io.Discard, "", 0),
+	}
+	return s, dbh
+}
+
+type mediaRow struct {
+	id                                         int
+	mediaType, title, overview, director, path string
+	year                                       int
+	poster                                     []byte
+}
+
+func readMediaRows(t *testing.T, dbh *db.DBHandler) []mediaRow {
+	t.Helper()
+	rows, err := dbh.DB.Query("SELECT id, media_type, title, year, overview, director, poster, path FROM media ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var result []mediaRow
+	for rows.Next() {
+		var r mediaRow
+		if err := rows.Scan(&r.id, &r.mediaType, &r.title, &r.year, &r.overview, &r.director, &r.poster, &r.path); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func TestScanFolder(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptYes)
+	ctx := context.Background()
+	status, err := s.scanFolder(ctx, mediaFolder{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979})
+	if err != nil || status != scanMatched {
+		t.Fatalf("got status %v and error %v, want a match", status, err)
+	}
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 1 {
+		t.Fatalf("got %v rows, want 1", len(rows))
+	}
+	alien := rows[0]
+	if alien.id != 348 || alien.mediaType != api.MediaTypeMovie || alien.title != "Alien" || alien.year != 1979 ||
+		alien.director != "Ridley Scott" || alien.path != "/films/Alien (1979)" || alien.overview == "" {
+		t.Errorf("wrote %+v", alien)
+	}
+	poster, err := os.ReadFile("../internal/api/apitest/testdata/t/p/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(alien.poster, poster) {
+		t.Errorf("wrote %v bytes of poster, want the %v bytes of the recorded one", len(alien.poster), len(poster))
+	}
+	credits, err := dbh.PersonCredits("Ridley Scott")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credits) != 1 || credits[0].Media.ID != 348 || credits[0].Role != api.RoleDirector {
+		t.Errorf("wrote credits %+v for Ridley Scott, want Alien's director", credits)
+	}
+	record, found, err := dbh.FindMedia("Alien", 1979, 0)
+	if err != nil || !found || record.GetReleaseDate() != "1979-05-25" || record.GetRuntime() != 117 || record.IMDbID != "tt0078748" ||
+		!slices.Equal(record.GenreNames(), []string{"Horror", "Science Fiction"}) {
+		t.Errorf("wrote details %+v, want Alien's", record.Media)
+	}
+	for _, kind := range []string{api.ArtworkBackdrop, api.ArtworkLogo} {
+		if a, found, err := dbh.Artwork(348, kind); err != nil || !found || len(a.Data) == 0 {
+			t.Errorf("wrote %v %+v, %v, %v, want Alien's", kind, a, found, err)
+		}
+	}
+
+	if status, err := s.scanFolder(ctx, mediaFolder{Path: "/films/Nothing matches this (2000)", Title: "Nothing matches this", Year: 2000}); err != nil || status != scanSkipped {
+		t.Errorf("unknown title: got status %v and error %v, want a skip", status, err)
+	}
+	if status, err := s.scanFolder(ctx, mediaFolder{Path: "/films/Alien (1960)", Title: "Alien", Year: 1960}); err != nil || status != scanSkipped {
+		t.Errorf("wrong year: got status %v and error %v, want a skip", status, err)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 1 {
+		t.Errorf("got %v rows, want 1", len(rows))
+	}
+}
+
+func TestScanFolderWriteFails(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptYes)
+	if _, err := dbh.DB.Exec("CREATE TRIGGER no_credits BEFORE INSERT ON credits BEGIN SELECT RAISE(FAIL, 'no credits'); END"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := s.scanFolder(context.Background(), mediaFolder{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979})
+	if err == nil || status != scanFailed {
+		t.Fatalf("got status %v and error %v, want a failure", status, err)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 0 {
+		t.Errorf("failed write left %+v", rows)
+	}
+}
+
+func TestScanFolderNo(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptNo)
+	if status, err := s.scanFolder(context.Background(), mediaFolder{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979}); err != nil || status != scanSkipped {
+		t.Errorf("got status %v and error %v, want a skip", status, err)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 0 {
+		t.Errorf("got %v rows, want none", len(rows))
+	}
+}
+
+func TestScanFolderAuto(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	ctx := context.Background()
+	tests := []struct {
+		title     string
+		year      int
+		tolerance int
+		want      scanStatus
+	}{
+		// Exact title, single candidate within tolerance.
+		{"alien", 1980, 2, scanMatched},
+		// Movies named Heat in 1986 and 1995, both within tolerance.
+		{"Heat", 1990, 5, scanQueued},
+		// Closest match is Alien (1979), too far.
+		{"Alien", 1970, 2, scanQueued},
+		// Closest match is Aliens, title isn't exact.
+		{"Alien", 1987, 2, scanQueued},
+		// No results, the folder name may be wrong.
+		{"Nothing matches this", 2000, 2, scanQueued},
+	}
+	for _, test := range tests {
+		s.tolerance = test.tolerance
+		status, err := s.scanFolder(ctx, mediaFolder{Path: fmt.Sprintf("/films/%v (%v)", test.title, test.year), Title: test.title, Year: test.year})
+		if err != nil || status != test.want {
+			t.Errorf("scanFolder(%q, %v): got status %v and error %v, want %v", test.title, test.year, status, err, test.want)
+		}
+	}
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 1 || rows[0].id != 348 {
+		t.Errorf("wrote %+v, want only Alien", rows)
+	}
+	items, err := dbh.ReviewQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 {
+		t.Errorf("queued %+v, want Heat, Alien twice and Nothing matches this", items)
+	}
+	for _, item := range items {
+		if item.Title == "Nothing matches this" && (item.Candidate.ID != 0 || item.Reason != "no results on TMDB.org") {
+			t.Errorf("queued %+v, want no candidate", item)
+		}
+	}
+}
+
+func TestScanRecursive(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	root := t.TempDir()
+	for _, dir := range []string{
+		"A/Alien (1979)/extras/Making of (2003)",
+		"H/Heat (1990)",
+		"Nothing matches this (2000)",
+		"unsorted",
+		".hidden/Heat (1995)",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary := s.scanRecursive(context.Background(), root)
+	want := scanSummary{
+		Matched: []string{filepath.Join(root, "A/Alien (1979)")},
+		Queued:  []string{filepath.Join(root, "H/Heat (1990)"), filepath.Join(root, "Nothing matches this (2000)")},
+	}
+	if !slices.Equal(summary.Matched, want.Matched) || !slices.Equal(summary.Skipped, want.Skipped) ||
+		!slices.Equal(summary.Queued, want.Queued) || len(summary.Failed) != 0 {
+		t.Errorf("got summary %+v, want %+v", summary, want)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 1 || rows[0].path != filepath.Join(root, "A/Alien (1979)") {
+		t.Errorf("wrote %+v, want only Alien", rows)
+	}
+}
+
+func TestScanFoldersConcurrent(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptYes)
+	s.jobs = 8
+	var folders []mediaFolder
+	var want scanSummary
+	// More than a batch, to commit several.
+	for i := range 2*scanBatchSize + 3 {
+		f := mediaFolder{Path: fmt.Sprintf("/films/%03d", i)}
+		switch i % 3 {
+		case 0:
+			f.Title, f.Year = "Alien", 1979
+			want.Matched = append(want.Matched, f.Path)
+		case 1:
+			f.Title, f.Year = "Heat", 1995
+			want.Matched = append(want.Matched, f.Path)
+		case 2:
+			f.Title, f.Year = "Nothing matches this", 2000
+			want.Skipped = append(want.Skipped, f.Path)
+		}
+		folders = append(folders, f)
+	}
+	summary := s.scanFolders(context.Background(), folders)
+	if !slices.Equal(summary.Matched, want.Matched) || !slices.Equal(summary.Skipped, want.Skipped) ||
+		len(summary.Queued) != 0 || len(summary.Failed) != 0 {
+		t.Errorf("got summary %+v, want %+v", summary, want)
+	}
+	// Writes are committed in folder order: the last Alien and Heat folders win.
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 2 || rows[0].path != "/films/102" || rows[1].path != "/films/100" {
+		t.Errorf("wrote %+v, want the last Alien and Heat folders", rows)
+	}
+}
+
+func TestScanFoldersCache(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptYes)
+	s.jobs = 4
+	client := s.providers[0].(*api.TMDBClient)
+	client.Cache = db.NewResponseCache(dbh, time.Hour)
+	summary := s.scanFolders(context.Background(), []mediaFolder{
+		{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979},
+		{Path: "/films/Heat (1995)", Title: "Heat", Year: 1995},
+	})
+	if len(summary.Matched) != 2 {
+		t.Fatalf("got summary %+v, want 2 matches", summary)
+	}
+	// The workers' responses are written with the batch.
+	var rows int
+	if err := dbh.DB.QueryRow("SELECT count(*) FROM api_cache").Scan(&rows); err != nil || rows == 0 {
+		t.Errorf("got %v cached responses, %v, want some", rows, err)
+	}
+	if _, ok := client.Cache.(db.ResponseCache); !ok || s.caches != nil {
+		t.Errorf("cache not restored, got %T", client.Cache)
+	}
+}
+
+func TestScanFoldersCanceled(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptYes)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary := s.scanFolders(ctx, []mediaFolder{{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979}})
+	if len(summary.Failed) != 1 {
+		t.Errorf("got summary %+v, want a failure", summary)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 0 {
+		t.Errorf("wrote %+v, want nothing", rows)
+	}
+}
+
+func TestScanDryRun(t *testing.T) {
+	// Without --yes, --no or --auto, a dry run doesn't prompt either.
+	s, dbh := newTestScanner(t, acceptAsk)
+	s.dryRun, s.policy = true, s.policy.forDryRun(true)
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Overview: "old overview", Director: "Ridley Scott"}
+	alien.SetTitleAndYear("Alien", 1979)
+	if _, err := dbh.WriteToDB(alien, "/old/Alien (1979)"); err != nil {
+		t.Fatal(err)
+	}
+	// Found by title, but the match is written under its own id.
+	heat := api.Media{ID: -113277, MediaType: api.MediaTypeMovie}
+	heat.SetTitleAndYear("Heat", 1995)
+	if _, err := dbh.WriteToDB(heat, "/old/Heat (1995)"); err != nil {
+		t.Fatal(err)
+	}
+	folders := []mediaFolder{
+		{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979},
+		{Path: "/films/Heat (1995)", Title: "Heat", Year: 1995},
+		{Path: "/films/Nothing matches this (2000)", Title: "Nothing matches this", Year: 2000},
+	}
+	summary := s.scanFolders(context.Background(), folders)
+	var actions []planAction
+	for _, p := range summary.Plans {
+		actions = append(actions, p.Action)
+	}
+	if want := []planAction{planUpdate, planInsert, planKeep}; !slices.Equal(actions, want) {
+		t.Fatalf("planned %v, want %v", actions, want)
+	}
+	var fields []string
+	for _, c := range summary.Plans[0].Changes {
+		fields = append(fields, c.Field)
+	}
+	want := []string{"release_date", "overview", "tagline", "genres", "runtime", "vote_average", "original_title", "original_language", "imdb_id", "path", "poster"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("update changes %v, want %v", fields, want)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 2 || rows[1].path != "/old/Alien (1979)" {
+		t.Errorf("dry run wrote %+v", rows)
+	}
+	var b bytes.Buffer
+	if err := writePlansJSON(&b, summary.Plans); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []scanPlan
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[0].Changes[1].Old != "old overview" || decoded[1].Media.ID != 949 {
+		t.Errorf("JSON plans decoded to %+v", decoded)
+	}
+}
+
+func TestScanPinned(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	server := apitest.NewServer(t)
+	s.providers = []api.MetadataProvider{server.TMDBClient()}
+	folders := []mediaFolder{
+		// Heat (1986) would be ambiguous with a search.
+		{Path: "/films/Heat (1995) {tmdb-949}", Title: "Heat", Year: 1995, TMDBID: 949},
+		{Path: "/films/Alien", Title: "Alien", IMDbID: "tt0078748"},
+		// No movie has this id, the TV show does.
+		{Path: "/shows/The Expanse (2015) {tmdb-63639}", Title: "The Expanse", Year: 2015, TMDBID: 63639},
+		{Path: "/films/Unknown {tmdb-1}", Title: "Unknown", TMDBID: 1},
+		// The movie with this id is Heat, no TV show has it.
+		{Path: "/shows/The Expanse (2015) {tmdb-949}", Title: "The Expanse", Year: 2015, TMDBID: 949},
+	}
+	summary := s.scanFolders(context.Background(), folders)
+	if len(summary.Matched) != 3 || len(summary.Failed) != 1 || !errors.Is(summary.Failed[0].Err, api.ErrNotFound) ||
+		!slices.Equal(summary.Queued, []string{"/shows/The Expanse (2015) {tmdb-949}"}) {
+		t.Errorf("got summary %+v, want 3 matches, a not found and a mismatch queued", summary)
+	}
+	for _, request := range server.Requests() {
+		if strings.Contains(request, api.SearchMultiEndPoint) {
+			t.Errorf("requested %v, want no search", request)
+		}
+	}
+	rows := readMediaRows(t, dbh)
+	var ids []int
+	for _, row := range rows {
+		ids = append(ids, row.id)
+	}
+	if want := []int{348, 949, 63639}; !slices.Equal(ids, want) {
+		t.Errorf("wrote ids %v, want %v", ids, want)
+	}
+}
+
+func TestScanPinnedShowFolder(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	server := apitest.NewServer(t)
+	s.providers = []api.MetadataProvider{server.TMDBClient()}
+	root := filepath.Join(t.TempDir(), "The Expanse {tmdb-63639}")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "The.Expanse.S01E01.mkv"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Episode files make the id a TV show's.
+	f := mediaFolder{Path: root, Title: "The Expanse", TMDBID: 63639}
+	if status, err := s.scanFolder(context.Background(), f); err != nil || status != scanMatched {
+		t.Fatalf("got status %v and error %v, want a match", status, err)
+	}
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "/3/movie/") {
+			t.Errorf("requested %v, want the TV show only", request)
+		}
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 1 || rows[0].mediaType != api.MediaTypeTV {
+		t.Errorf("wrote %+v, want The Expanse", rows)
+	}
+}
+
+func TestScanShow(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	root := filepath.Join(t.TempDir(), "The Expanse")
+	files := map[string]string{
+		".tmdb":                                 "tv/63639",
+		"Season 1/The.Expanse.S01E01.1080p.mkv": "",
+		"Season 1/The Expanse S01E02.mkv":       "",
+		"Season 1/The Expanse S01E09.mkv":       "",
+		"Season 1/notes.txt":                    "",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := readMediaFolder(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := s.scanFolder(context.Background(), f); err != nil || status != scanMatched {
+		t.Fatalf("got status %v and error %v, want a match", status, err)
+	}
+	if rows := readMediaRows(t, dbh); len(rows) != 1 || rows[0].mediaType != api.MediaTypeTV || rows[0].title != "The Expanse" {
+		t.Errorf("wrote %+v, want The Expanse", rows)
+	}
+	episodes, err := dbh.Episodes(63639)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Episodes of a season with files are written, even those without a file.
+	want := []string{filepath.Join(root, "Season 1/The.Expanse.S01E01.1080p.mkv"), filepath.Join(root, "Season 1/The Expanse S01E02.mkv"), ""}
+	if len(episodes) != len(want) {
+		t.Fatalf("wrote %+v, want %v episodes", episodes, len(want))
+	}
+	for i, e := range episodes {
+		if e.Path != want[i] || e.EpisodeNumber != i+1 || e.Name == "" || e.Overview == "" || e.AirDate == "" {
+			t.Errorf("episode %v is %+v, want path %q", i+1, e, want[i])
+		}
+	}
+	var still []byte
+	if err := dbh.DB.QueryRow("SELECT still FROM episodes WHERE episode_number=1").Scan(&still); err != nil || len(still) == 0 {
+		t.Errorf("wrote still %v, %v, want the recorded one", len(still), err)
+	}
+}
+
+func TestScanOffline(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	server := apitest.NewServer(t)
+	s.providers = []api.MetadataProvider{server.TMDBClient()}
+	s.offline = true
+	root := t.TempDir()
+	files := map[string]string{
+		"Alien (1979)/movie.nfo":        `<movie><title>Alien</title><premiered>1979-05-25</premiered><uniqueid type="tmdb">348</uniqueid></movie>`,
+		"Alien (1979)/folder.jpg":       "alien poster",
+		"The Expanse/tvshow.nfo":        `<tvshow><title>The Expanse</title><year>2015</year><tmdbid>63639</tmdbid></tvshow>`,
+		"Heat (1995)/Heat.nfo":          `<movie><title>Heat</title><year>1995</year><uniqueid type="imdb">tt0113277</uniqueid></movie>`,
+		"Taken (2008)/Taken (2008).mkv": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary := s.scanRecursive(context.Background(), root)
+	if len(summary.Matched) != 2 || len(summary.Skipped) != 2 || len(summary.Failed) != 0 {
+		t.Errorf("got summary %+v, want Alien and The Expanse matched, Heat and Taken skipped", summary)
+	}
+	if requests := server.Requests(); len(requests) != 0 {
+		t.Errorf("requested %v offline", requests)
+	}
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 2 || rows[0].title != "Alien" || rows[0].year != 1979 || string(rows[0].poster) != "alien poster" ||
+		rows[1].id != 63639 || rows[1].mediaType != api.MediaTypeTV {
+		t.Errorf("got rows %+v", rows)
+	}
+}
+
+func TestScanFallback(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	tmdb, omdb := apitest.NewServer(t), apitest.NewOMDbServer(t)
+	s.providers = []api.MetadataProvider{tmdb.TMDBClient(), omdb.OMDbClient()}
+	folders := []mediaFolder{
+		{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979},
+		// TMDB.org lacks these.
+		{Path: "/films/Sorcerer (1977)", Title: "Sorcerer", Year: 1977},
+		{Path: "/shows/The Expanse", Title: "The Expanse", IMDbID: "tt3230854"},
+	}
+	summary := s.scanFolders(context.Background(), folders)
+	if len(summary.Matched) != 3 {
+		t.Fatalf("got summary %+v, want 3 matches", summary)
+	}
+	for _, request := range omdb.Requests() {
+		if strings.Contains(request, "s=Alien") {
+			t.Errorf("requested %v from OMDb, want Alien found on TMDB.org", request)
+		}
+	}
+	rows := readMediaRows(t, dbh)
+	if len(rows) != 3 || rows[0].id != -3230854 || rows[0].mediaType != api.MediaTypeTV || rows[1].id != -76740 ||
+		rows[1].director != "William Friedkin" || len(rows[1].poster) == 0 || rows[2].id != 348 {
+		t.Errorf("got rows %+v", rows)
+	}
+}
+
+func TestScanLanguage(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	client := apitest.NewServer(t).TMDBClient()
+	client.Language = "fr"
+	s.providers = []api.MetadataProvider{client}
+	folders := []mediaFolder{
+		// Localized title, matched by original title.
+		{Path: "/films/Alien (1979)", Title: "Alien", Year: 1979},
+		// No French results, found by original title.
+		{Path: "/films/Heat (1995)", Title: "Heat", Year: 1995},
+	}
+	summary := s.scanFolders(context.Background(), folders)
+	if len(summary.Matched) != 2 {
+		t.Fatalf("got summary %+v, want 2 matches", summary)
+	}
+	record, found, err := dbh.FindMedia("Alien", 1979, 0)
+	if err != nil || !found || record.GetTitle() != "Alien, le huitième passager" ||
+		!strings.HasPrefix(record.Overview, "Durant le voyage") || !strings.HasPrefix(record.OriginalOverview, "During its return") {
+		t.Errorf("FindMedia(Alien) = %+v, %v, %v, want French title and overview, and the English overview", record.Media, found, err)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <terms>",
+	Short: "Searches the titles, overviews and directors of the database",
+	Long: `Lists the media whose title, original title, overview or director have all the words of <terms>, best match first.
+Case and accents are ignored, and the last word matches as a prefix: 'amel' finds 'Amélie'.
+--format is the same as list's.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		terms := strings.Join(args, " ")
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			log.Fatalln(" Couldn't read limit flag from config")
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			log.Fatalln(" Couldn't read format flag from config")
+		}
+		write, err := newListWriter(format)
+		if err != nil {
+			log.Fatalln(" Invalid format: ", err)
+		}
+		records, err := localConfig.DBH.SearchMedia(terms, limit)
+		if err != nil {
+			log.Fatalln(" Couldn't search media: ", err)
+		}
+		if len(records) == 0 && format == "table" {
+			fmt.Printf("∅ Found nothing matching %q.\n", terms)
+			return
+		}
+		rows := make([]listRow, 0, len(records))
+		for _, r := range records {
+			rows = append(rows, newListRow(r))
+		}
+		if err := write(os.Stdout, rows); err != nil {
+			log.Fatalln(" Couldn't print media: ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().Int("limit", 20, "list at most this many media, 0 means no limit")
+	searchCmd.Flags().String("format", "table", "output format: table, json, csv or a Go template")
+}
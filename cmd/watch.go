@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// libraryWatcher scans the media folders added to a library tree, and updates the paths of those renamed in it.
+type libraryWatcher struct {
+	s    *scanner
+	root string
+	fsw  *fsnotify.Watcher
+	// delay is how long a path must go without events before it's handled.
+	delay time.Duration
+	// pending are the paths with unhandled events, and the time of their last event.
+	pending map[string]time.Time
+	// known are the folders of the media records under root, to recognize them once renamed.
+	known map[string]os.FileInfo
+	// settling are the new media folders watched until they go without events for delay, with their watched folders.
+	// Their copy may still be running when they show up.
+	settling map[string][]string
+}
+
+// newLibraryWatcher watches root, an absolute path, and its folders that aren't media folders.
+func newLibraryWatcher(s *scanner, root string, delay time.Duration) (*libraryWatcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &libraryWatcher{s: s, root: root, fsw: fsw, delay: delay, pending: make(map[string]time.Time), known: make(map[string]os.FileInfo),
+		settling: make(map[string][]string)}
+	records, err := s.dbh.ListMedia(db.MediaFilter{Sort: "path"})
+	if err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("listing media: %w", err)
+	}
+	for _, r := range records {
+		if !isUnder(root, r.Path) {
+			continue
+		}
+		if info, err := os.Stat(r.Path); err == nil {
+			w.known[r.Path] = info
+		}
+	}
+	if _, err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *libraryWatcher) Close() error { return w.fsw.Close() }
+
+// addTree watches dir and the folders under it, except media folders and hidden ones, and returns the media folders.
+// New media folders show up as events of their parent folder.
+func (w *libraryWatcher) addTree(dir string) (folders []string, err error) {
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			w.s.printf(" Couldn't read %v: %v\n", p, err)
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != w.root && strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		if p != w.root {
+			if _, err := readMediaFolder(p); err == nil {
+				folders = append(folders, p)
+				return fs.SkipDir
+			}
+		}
+		if err := w.fsw.Add(p); err != nil {
+			w.s.printf(" Couldn't watch %v: %v\n", p, err)
+		}
+		return nil
+	})
+	return folders, err
+}
+
+// Run handles the events of the watched folders until ctx is done, or watching fails.
+func (w *libraryWatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(max(w.delay/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.s.printf(" Missed events, run doctor to find the folders missing from the database: %v\n", err)
+				continue
+			}
+			return err
+		case e, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if f := w.settlingFolder(e.Name); f != "" {
+				w.pending[f] = time.Now()
+				if e.Has(fsnotify.Create) {
+					w.watchIn(f, e.Name)
+				}
+				continue
+			}
+			if e.Has(fsnotify.Create) || e.Has(fsnotify.Rename) || e.Has(fsnotify.Remove) {
+				w.pending[e.Name] = time.Now()
+			}
+		case now := <-ticker.C:
+			var settled []string
+			for p, last := range w.pending {
+				if now.Sub(last) >= w.delay {
+					settled = append(settled, p)
+					delete(w.pending, p)
+				}
+			}
+			if len(settled) > 0 {
+				slices.Sort(settled)
+				w.handle(ctx, settled)
+			}
+		}
+	}
+}
+
+// settlingFolder returns the settling media folder holding path, if any.
+func (w *libraryWatcher) settlingFolder(path string) string {
+	for f := range w.settling {
+		if path != f && isUnder(f, path) {
+			return f
+		}
+	}
+	return ""
+}
+
+// watchIn watches path and the folders under it if it's a folder, as part of the settling media folder f.
+func (w *libraryWatcher) watchIn(f string, path string) {
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if err := w.fsw.Add(p); err != nil {
+			w.s.printf(" Couldn't watch %v: %v\n", p, err)
+			return nil
+		}
+		w.settling[f] = append(w.settling[f], p)
+		return nil
+	})
+}
+
+// startSettling watches the new media folder f until it goes without events for delay, see settling.
+func (w *libraryWatcher) startSettling(f string) {
+	w.settling[f] = nil
+	w.watchIn(f, f)
+	w.pending[f] = time.Now()
+}
+
+// stopSettling stops watching the media folder f.
+func (w *libraryWatcher) stopSettling(f string) {
+	for _, p := range w.settling[f] {
+		// Removed folders aren't watched anymore.
+		w.fsw.Remove(p)
+	}
+	delete(w.settling, f)
+}
+
+// handle handles the paths with settled events: media folders are matched to renamed records,
+// new ones are watched until they settle and then scanned,
+// new folders are watched, and the records whose folder is gone are reported.
+func (w *libraryWatcher) handle(ctx context.Context, paths []string) {
+	var folders, gone []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			gone = append(gone, p)
+			if _, ok := w.settling[p]; ok {
+				w.stopSettling(p)
+			}
+			continue
+		}
+		if !info.IsDir() {
+			continue
+		}
+		if _, err := readMediaFolder(p); err == nil {
+			folders = append(folders, p)
+			continue
+		}
+		found, err := w.addTree(p)
+		if err != nil {
+			w.s.printf(" Couldn't watch %v: %v\n", p, err)
+		}
+		folders = append(folders, found...)
+	}
+	for _, f := range folders {
+		if err := w.settle(ctx, f); err != nil {
+			w.s.printf(" %v: %v\n", f, err)
+		}
+	}
+	// Once the renames are matched, what's left is gone.
+	for _, p := range gone {
+		for path := range w.known {
+			if !isUnder(p, path) {
+				continue
+			}
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				w.s.printf(" %v was deleted, run doctor --fix to delete its media from the database\n", path)
+			}
+		}
+	}
+}
+
+// settle updates the record of the media folder at path if it was renamed.
+// If it's new, it's watched until it settles, and then scanned.
+func (w *libraryWatcher) settle(ctx context.Context, path string) error {
+	if _, ok := w.known[path]; ok {
+		return nil
+	}
+	if _, ok := w.settling[path]; ok {
+		w.stopSettling(path)
+		return w.scan(ctx, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	for old, oldInfo := range w.known {
+		if !os.SameFile(info, oldInfo) {
+			continue
+		}
+		if _, err := os.Stat(old); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if _, _, err := w.s.dbh.Relocate(old, path, func(db.PathRewrite) bool { return true }, false); err != nil {
+			return fmt.Errorf("DB write error: %w", err)
+		}
+		delete(w.known, old)
+		w.known[path] = info
+		w.s.printf("✓ Moved %v to %v in DB\n", old, path)
+		return nil
+	}
+	w.startSettling(path)
+	return nil
+}
+
+// scan scans the new media folder at path.
+func (w *libraryWatcher) scan(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := readMediaFolder(path)
+	if err != nil {
+		return err
+	}
+	w.s.printf("Scanning %v\n", path)
+	status, err := w.s.scanFolder(ctx, f)
+	if status == scanMatched {
+		w.known[path] = info
+	}
+	return err
+}
+
+// openWatchLog opens the log file at path for appending, creating it if needed.
+func openWatchLog(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch [root]",
+	Short: "Watches the library for new media folders and scans them",
+	Long: `Watches the library tree under root (default: current folder) and scans the media folders added to it, like scan --recursive --auto does.
+A folder is handled once it has had no event for --delay, and a new media folder is scanned once nothing in it has changed for --delay, so that copies can finish.
+A media folder renamed or moved in the tree has its paths updated in the database, a deleted one is reported, see doctor --fix to delete it.
+Everything is logged to --log as well as to stdout. Folders added while not watching are found by doctor.`,
+	Example: `  mymedia watch /mnt/films
+  mymedia watch --delay 30s --log /var/log/mymedia.log /mnt/films`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		delay, err := cmd.Flags().GetDuration("delay")
+		if err != nil {
+			log.Fatalln(" Couldn't read delay flag from config")
+		}
+		logPath, err := cmd.Flags().GetString("log")
+		if err != nil {
+			log.Fatalln(" Couldn't read log flag from config")
+		}
+		if logPath == "" {
+			logPath = filepath.Join(filepath.Dir(localConfig.DBH.Path), "watch.log")
+		}
+		logFile, err := openWatchLog(logPath)
+		if err != nil {
+			log.Fatalln(" Couldn't open log file: ", err)
+		}
+		defer logFile.Close()
+		out := log.New(io.MultiWriter(os.Stdout, logFile), "", log.LstdFlags)
+
+		root := "."
+		if len(args) == 1 {
+			root = args[0]
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			log.Fatalln(" Couldn't resolve library root: ", err)
+		}
+		providers := newProviders()
+		setProvidersLogger(providers, out)
+		s := &scanner{
+			providers: providers,
+			dbh:       localConfig.DBH,
+			tolerance: parseTolerance(cmd),
+			policy:    acceptPolicy{mode: acceptAuto},
+			jobs:      1,
+			out:       out,
+		}
+		w, err := newLibraryWatcher(s, root, delay)
+		if err != nil {
+			out.Fatalln(" Couldn't watch library: ", err)
+		}
+		defer w.Close()
+		out.Printf("Watching %v, logging to %v\n", root, logPath)
+		if err := w.Run(cmd.Context()); err != nil {
+			out.Fatalln(" Watching failed: ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().Duration("delay", 10*time.Second, "time without events after which a new folder is handled")
+	watchCmd.Flags().String("log", "", "log file, default: watch.log next to the database")
+	watchCmd.Flags().Int("tolerance", 2, "on lookup, result will be accepted if title match and year is within tolerance of result")
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/db"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	var out syncBuffer
+	s.out = log.New(&out, "", 0)
+	root := t.TempDir()
+	for _, dir := range []string{"Alien (1979)", "Aliens (1986)", "unsorted"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []api.Media{
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"},
+		{ID: 679, MediaType: api.MediaTypeMovie, Title: "Aliens", ReleaseDate: "1986-07-18"},
+	} {
+		if _, err := dbh.WriteToDB(m, filepath.Join(root, m.Title+" ("+m.ReleaseDate[:4]+")")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := newLibraryWatcher(s, root, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	renamed := filepath.Join(root, "unsorted", "Alien (1979) {tmdb-348}")
+	if err := os.Rename(filepath.Join(root, "Alien (1979)"), renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "Heat (1995)"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "Aliens (1986)")); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{348: renamed, 679: filepath.Join(root, "Aliens (1986)"), 949: filepath.Join(root, "Heat (1995)")}
+	var records []db.Record
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if records, err = dbh.ListMedia(db.MediaFilter{Sort: "id"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == len(want) && records[0].Path == want[348] && records[2].Path == want[949] &&
+			strings.Contains(out.String(), want[679]+" was deleted") {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.Path != want[r.ID] {
+			t.Errorf("%v is at %v, want %v", r.GetTitle(), r.Path, want[r.ID])
+		}
+	}
+	if len(records) != len(want) {
+		t.Errorf("got %v records, want %v", len(records), len(want))
+	}
+	if !strings.Contains(out.String(), want[679]+" was deleted") {
+		t.Errorf("didn't report Aliens deleted, logged:\n%v", out.String())
+	}
+}
+
+func TestWatchSlowCopy(t *testing.T) {
+	s, dbh := newTestScanner(t, acceptAuto)
+	s.out = log.New(io.Discard, "", 0)
+	root := t.TempDir()
+	w, err := newLibraryWatcher(s, root, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// Copied in for longer than the delay, the sidecar last: the name alone gives the wrong year.
+	folder := filepath.Join(root, "Heat (1986)")
+	if err := os.MkdirAll(filepath.Join(folder, "extras"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if err := os.WriteFile(filepath.Join(folder, "extras", fmt.Sprintf("part%v.mkv", i)), []byte("video"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(40 * time.Millisecond)
+	}
+	if err := os.WriteFile(filepath.Join(folder, ".tmdb"), []byte("movie/949\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var records []db.Record
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && len(records) == 0; time.Sleep(20 * time.Millisecond) {
+		if records, err = dbh.ListMedia(db.MediaFilter{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != 949 || records[0].Path != folder {
+		t.Errorf("got records %+v, want Heat (1995) pinned by its sidecar", records)
+	}
+	if len(w.settling) != 0 {
+		t.Errorf("still settling %v", w.settling)
+	}
+}
@@ -1,11 +1,18 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/JeanLeonHenry/mymedia/internal/api"
 	"github.com/JeanLeonHenry/mymedia/internal/db"
 	"github.com/profclems/go-dotenv"
 )
@@ -13,13 +20,39 @@ import (
 type Config struct {
 	DBH              *db.DBHandler
 	DefaultTolerance int
-	// ApiUrl           string
-	// ImageApiUrl      string
+	// ApiUrl and ImageApiUrl are optional, they default to TMDB.org's.
+	ApiUrl      string
+	ImageApiUrl string
+	// ArtworkSizes (POSTER_SIZE, BACKDROP_SIZE, LOGO_SIZE and STILL_SIZE) are optional, empty sizes use the api package defaults.
+	ArtworkSizes api.ArtworkSizes
+	// ApiRateLimit (requests per second), ApiMaxRetries and ApiTimeout (per try of a request) are optional,
+	// zero uses the api package defaults.
+	ApiRateLimit  float64
+	ApiMaxRetries int
+	ApiTimeout    time.Duration
+	// ApiCacheTTL is how long api responses are cached in the db, zero uses the default and a negative value disables the cache.
+	ApiCacheTTL  time.Duration
 	ApiReadToken string
 	ApiKey       string
-	IsValid      bool
+	// MetadataProviders names the providers scan looks media up on, the primary one first and then its fallbacks:
+	// tmdb or omdb. It defaults to tmdb only.
+	MetadataProviders []string
+	// OMDbApiKey authenticates calls to OMDb, it's needed if omdb is a provider. OMDbApiUrl is optional.
+	OMDbApiKey string
+	OMDbApiUrl string
+	// Language (MYMEDIA_LANGUAGE, e.g. fr or fr-FR) is the language of the titles and overviews asked to TMDB.org, English if empty.
+	// Region (MYMEDIA_REGION, e.g. FR) defaults to the region of Language, if it has one.
+	Language string
+	Region   string
+	IsValid  bool
 }
 
+// Names of the metadata providers, see Config.MetadataProviders.
+const (
+	ProviderTMDB = "tmdb"
+	ProviderOMDb = "omdb"
+)
+
 func New() *Config {
 	dotenv.SetConfigFile(path.Join(os.Getenv("HOME"), ".config/mymedia/.env"))
 	dbPath := dotenv.GetString("DB_PATH")
@@ -27,32 +60,167 @@ func New() *Config {
 		log.Fatal("DB_PATH is empty, check config file.")
 	}
 
-	return &Config{
-		DBH:              db.NewDB(dbPath),
-		DefaultTolerance: 2,
-		IsValid:          true,
+	c := &Config{
+		DBH:               db.NewDB(dbPath),
+		DefaultTolerance:  2,
+		ApiUrl:            dotenv.GetString("API_URL"),
+		ApiRateLimit:      dotenv.GetFloat64("API_RATE_LIMIT"),
+		ApiMaxRetries:     dotenv.GetInt("API_MAX_RETRIES"),
+		ApiTimeout:        dotenv.GetDuration("API_TIMEOUT"),
+		ApiCacheTTL:       dotenv.GetDuration("API_CACHE_TTL"),
+		MetadataProviders: parseProviders(dotenv.GetString("METADATA_PROVIDERS")),
+		OMDbApiUrl:        dotenv.GetString("OMDB_API_URL"),
+		IsValid:           true,
+	}
+	c.ArtworkSizes = api.ArtworkSizes{
+		PosterSize:   dotenv.GetString("POSTER_SIZE"),
+		BackdropSize: dotenv.GetString("BACKDROP_SIZE"),
+		LogoSize:     dotenv.GetString("LOGO_SIZE"),
+		StillSize:    dotenv.GetString("STILL_SIZE"),
+	}
+	c.ImageApiUrl, c.ArtworkSizes.PosterSize = splitImageApiUrl(dotenv.GetString("IMAGE_API_URL"), c.ArtworkSizes.PosterSize)
+	c.SetLanguage(dotenv.GetString("MYMEDIA_LANGUAGE"), dotenv.GetString("MYMEDIA_REGION"))
+	return c
+}
+
+// SetLanguage sets the language and region of c, the region defaulting to the one of a language tag like fr-FR.
+func (c *Config) SetLanguage(language, region string) {
+	c.Language, c.Region = language, region
+	if _, tagRegion, ok := strings.Cut(language, "-"); ok && region == "" {
+		c.Region = strings.ToUpper(tagRegion)
+	}
+}
+
+// imageSize matches the sizes of TMDB.org images.
+var imageSize = regexp.MustCompile(`^(w\d+|h\d+|original)$`)
+
+// splitImageApiUrl returns imageApiUrl without its size, if it ends with one, e.g. http://image.tmdb.org/t/p/w500.
+// Image urls used to include the size of posters, which is returned unless posterSize is set.
+func splitImageApiUrl(imageApiUrl, posterSize string) (string, string) {
+	base, size, found := cutLast(strings.TrimSuffix(imageApiUrl, "/"), "/")
+	if !found || !imageSize.MatchString(size) {
+		return imageApiUrl, posterSize
+	}
+	return base, cmp.Or(posterSize, size)
+}
+
+// cutLast slices s around the last instance of sep, like strings.Cut does around the first.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
 	}
+	return s, "", false
+}
 
+// parseProviders reads a comma separated list of provider names, defaulting to tmdb.
+func parseProviders(value string) []string {
+	var providers []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			providers = append(providers, name)
+		}
+	}
+	if len(providers) == 0 {
+		return []string{ProviderTMDB}
+	}
+	return providers
 }
 
-func (c *Config) warningStringVarEmpty(val string, key string) {
-	if val == "" {
-		log.Printf("%v is empty, check config file.", key)
+// Check reads the api credentials of the metadata providers of c. If some are missing, or if a provider is unknown,
+// it sets c.IsValid to false and returns an error naming them.
+func (c *Config) Check() error {
+	var problems []string
+	configKeys := make(map[string]*string)
+	for _, name := range c.MetadataProviders {
+		switch name {
+		case ProviderTMDB:
+			configKeys["API_READ_TOKEN"] = &c.ApiReadToken
+			configKeys["API_KEY"] = &c.ApiKey
+		case ProviderOMDb:
+			configKeys["OMDB_API_KEY"] = &c.OMDbApiKey
+		default:
+			problems = append(problems, fmt.Sprintf("unknown metadata provider %v in METADATA_PROVIDERS", name))
+		}
+	}
+	keys := make([]string, 0, len(configKeys))
+	for key := range configKeys {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if *configKeys[key] = dotenv.GetString(key); *configKeys[key] == "" {
+			problems = append(problems, key+" is empty")
+		}
+	}
+	if len(problems) > 0 {
 		c.IsValid = false
+		return fmt.Errorf("%v, check config file", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// NewTMDBClient returns a TMDB.org client using the api settings of c, caching responses in c.DBH.
+func (c *Config) NewTMDBClient() *api.TMDBClient {
+	client := api.NewTMDBClient(c.ApiReadToken, c.ApiKey)
+	if c.ApiUrl != "" {
+		client.ApiBaseUrl = c.ApiUrl
+	}
+	if c.ImageApiUrl != "" {
+		client.ImgApiBaseUrl = c.ImageApiUrl
+	}
+	if c.ApiRateLimit > 0 {
+		client.Limiter = api.NewRateLimiter(c.ApiRateLimit)
 	}
+	client.Language, client.Region = c.Language, c.Region
+	client.PosterSize = cmp.Or(c.ArtworkSizes.PosterSize, client.PosterSize)
+	client.BackdropSize = cmp.Or(c.ArtworkSizes.BackdropSize, client.BackdropSize)
+	client.LogoSize = cmp.Or(c.ArtworkSizes.LogoSize, client.LogoSize)
+	client.StillSize = cmp.Or(c.ArtworkSizes.StillSize, client.StillSize)
+	c.setRequester(&client.Requester)
+	return client
 }
 
-func (c *Config) Check() {
-	configKeys := map[string]*string{
-		// "API_URL":        &c.ApiUrl,
-		// "IMAGE_API_URL":  &c.ImageApiUrl,
-		"API_READ_TOKEN": &c.ApiReadToken,
-		"API_KEY":        &c.ApiKey,
+// NewOMDbClient returns an OMDb client using the api settings of c, caching responses in c.DBH.
+// API_RATE_LIMIT is TMDB.org's, OMDb keeps its default.
+func (c *Config) NewOMDbClient() *api.OMDbClient {
+	client := api.NewOMDbClient(c.OMDbApiKey)
+	if c.OMDbApiUrl != "" {
+		client.ApiBaseUrl = c.OMDbApiUrl
 	}
-	for key, configField := range configKeys {
-		*configField = dotenv.GetString(key)
-		c.warningStringVarEmpty(*configField, key)
+	c.setRequester(&client.Requester)
+	return client
+}
+
+// setRequester applies the retry, timeout and cache settings of c to r.
+func (c *Config) setRequester(r *api.Requester) {
+	if c.ApiMaxRetries > 0 {
+		r.MaxRetries = c.ApiMaxRetries
+	}
+	if c.ApiTimeout > 0 {
+		r.RequestTimeout = c.ApiTimeout
+	}
+	if c.ApiCacheTTL > 0 {
+		r.CacheTTL = c.ApiCacheTTL
+	}
+	if c.ApiCacheTTL >= 0 {
+		r.Cache = db.NewResponseCache(c.DBH, r.CacheTTL)
+	}
+}
+
+// NewProviders returns the clients of the metadata providers of c, in order, see MetadataProviders.
+func (c *Config) NewProviders() ([]api.MetadataProvider, error) {
+	var providers []api.MetadataProvider
+	for _, name := range c.MetadataProviders {
+		switch name {
+		case ProviderTMDB:
+			providers = append(providers, c.NewTMDBClient())
+		case ProviderOMDb:
+			providers = append(providers, c.NewOMDbClient())
+		default:
+			return nil, fmt.Errorf("unknown metadata provider %v", name)
+		}
 	}
+	return providers, nil
 }
 
 func (c Config) String() string {
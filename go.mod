@@ -4,6 +4,7 @@ go 1.22.1
 
 require (
 	github.com/briandowns/spinner v1.23.1
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/go-playground/validator/v10 v10.22.0
 	github.com/junegunn/fzf v0.54.3
 	github.com/profclems/go-dotenv v0.1.2
@@ -1,85 +1,278 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
 	"regexp"
+	"time"
 )
 
 const SearchMultiEndPoint = "search/multi"
 const MovieEndpointPattern = `movie/\d+/credits`
+const AggregateCreditsEndpointPattern = `tv/\d+/aggregate_credits`
+const DetailsEndpointPattern = `(movie|tv)/\d+`
+const FindEndpointPattern = `find/tt\d+`
+const SeasonEndpointPattern = `tv/\d+/season/\d+`
 const SiteBaseUrl = "https://themoviedb.org"
 const ApiBaseUrl = "https://api.themoviedb.org/3"
-const ImgApiBaseUrl = "http://image.tmdb.org/t/p/w500"
+const ImgApiBaseUrl = "http://image.tmdb.org/t/p"
 
-func formUrl(baseUrl, endpoint string) string {
+// endpointPatterns lists the endpoints PollApi is allowed to query.
+var endpointPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^` + SearchMultiEndPoint + `$`),
+	regexp.MustCompile(`^` + MovieEndpointPattern + `$`),
+	regexp.MustCompile(`^` + AggregateCreditsEndpointPattern + `$`),
+	regexp.MustCompile(`^` + DetailsEndpointPattern + `$`),
+	regexp.MustCompile(`^` + FindEndpointPattern + `$`),
+	regexp.MustCompile(`^` + SeasonEndpointPattern + `$`),
+}
+
+// Logger is what the api clients log through. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Requester requests an api for its client: it rate limits, retries, times out and caches requests.
+// It's embedded in TMDBClient and OMDbClient.
+type Requester struct {
+	HTTPClient *http.Client
+	Logger     Logger
+	// Limiter spaces out the requests of the client, nil means no limit.
+	Limiter *RateLimiter
+	// MaxRetries is how many times a request failing on the network, a rate limit or a server error is tried again.
+	MaxRetries int
+	// RequestTimeout bounds each try of a request, 0 means no timeout.
+	RequestTimeout time.Duration
+	// BaseBackoff is the wait before the first retry, doubled on each retry up to MaxBackoff.
+	// A Retry-After given by the api takes precedence, the request fails if it's longer than MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Cache stores responses, keyed by url without credentials, nil means no cache.
+	Cache Cache
+	// CacheTTL is how long a cached response is used for.
+	CacheTTL time.Duration
+	// Refresh bypasses the cache on read, responses are still stored.
+	Refresh bool
+}
+
+// newRequester returns a requester logging to stdout, limited to perSecond requests per second,
+// with the default retries, timeout and cache TTL.
+func newRequester(perSecond float64) Requester {
+	return Requester{
+		HTTPClient:     http.DefaultClient,
+		Logger:         log.New(os.Stdout, "", 0),
+		Limiter:        NewRateLimiter(perSecond),
+		MaxRetries:     DefaultMaxRetries,
+		RequestTimeout: DefaultRequestTimeout,
+		BaseBackoff:    DefaultBaseBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		CacheTTL:       DefaultCacheTTL,
+	}
+}
+
+// TMDBClient queries TMDB.org.
+// Use NewTMDBClient to get one with sensible defaults, fields can be changed before use.
+type TMDBClient struct {
+	ApiBaseUrl string
+	// ImgApiBaseUrl is followed by the size of the image in its urls, see ArtworkSizes.
+	ImgApiBaseUrl string
+	ArtworkSizes
+	// ApiReadToken authenticates calls to ApiBaseUrl.
+	ApiReadToken string
+	// ApiKey authenticates calls to ImgApiBaseUrl.
+	ApiKey string
+	// Language, e.g. fr or fr-FR, is the language of the titles and overviews TMDB.org answers with, English if empty.
+	// Region, e.g. FR, is the country whose release dates TMDB.org answers with.
+	Language string
+	Region   string
+	Requester
+}
+
+// NewTMDBClient returns a client for the TMDB.org API that logs to stdout,
+// with the default rate limit, retries and timeout.
+func NewTMDBClient(apiReadToken, apiKey string) *TMDBClient {
+	return &TMDBClient{
+		ApiBaseUrl:    ApiBaseUrl,
+		ImgApiBaseUrl: ImgApiBaseUrl,
+		ArtworkSizes:  DefaultArtworkSizes,
+		ApiReadToken:  apiReadToken,
+		ApiKey:        apiKey,
+		Requester:     newRequester(DefaultRateLimit),
+	}
+}
+
+func (c *Requester) logf(format string, v ...any) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+	}
+}
+
+func formUrl(baseUrl, endpoint string, query url.Values) (string, error) {
 	fullUrl, err := url.JoinPath(baseUrl, endpoint)
 	if err != nil {
-		log.Fatalf(" Api url couldn't be formed:\nBase url: %v\nEndpoint: %v\n", baseUrl, endpoint)
+		return "", fmt.Errorf("api url couldn't be formed from base url %v and endpoint %v: %w", baseUrl, endpoint, err)
 	}
-	return fullUrl
-}
-func HTTPResponseBodyData(resp *http.Response, err error) []byte {
+	parsedUrl, err := url.Parse(fullUrl)
 	if err != nil {
-		log.Fatalf(" Error contacting the API: %v", err)
+		return "", fmt.Errorf("api url couldn't be parsed, check config file: %w", err)
 	}
-	data, err := io.ReadAll(resp.Body)
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String(), nil
+}
+
+// responseBodyData reads the body of resp, returning an *APIError if resp has an error status.
+func responseBodyData(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf(" Error reading the API's response")
+		return nil, fmt.Errorf("reading the API's response: %w", err)
 	}
 	if resp.StatusCode >= 400 {
-		log.Fatalln(" Api response error: ", data)
+		return nil, newAPIError(resp, data)
 	}
-	return data
+	return data, nil
 }
-func PollApi(endpoint, apiQuery, apiReadToken string) []byte {
-	if isMovieCreditsEndpoint, _ := regexp.MatchString(MovieEndpointPattern, endpoint); endpoint != SearchMultiEndPoint && !isMovieCreditsEndpoint {
-		log.Fatalf(" Api request error: endpoint was %v", endpoint)
+
+// get requests rawUrl, retrying with backoff when that may help.
+func (c *Requester) get(ctx context.Context, rawUrl string, header http.Header) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := c.try(ctx, rawUrl, header)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil || attempt >= c.MaxRetries || !isRetryable(err) {
+			return nil, err
+		}
+		wait, ok := backoff(attempt, err, c.BaseBackoff, c.MaxBackoff)
+		if !ok {
+			return nil, fmt.Errorf("%w, asked to retry in %v, later than %v", err, wait, c.MaxBackoff)
+		}
+		c.logf(" %v, retrying in %v\n", err, wait.Round(time.Millisecond))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
 	}
-	if apiQuery == "" {
-		fmt.Printf("󰍉 Searching TMDB.org on %v\n", endpoint)
-	} else {
-		fmt.Printf("󰍉 Searching TMDB.org on %v for %v\n", endpoint, apiQuery)
+}
+
+// try requests rawUrl once, waiting for the rate limiter first.
+func (c *Requester) try(ctx context.Context, rawUrl string, header http.Header) ([]byte, error) {
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
 	}
-	fullUrl := formUrl(ApiBaseUrl, endpoint)
-	parsedUrl, err := url.Parse(fullUrl)
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
 	if err != nil {
-		log.Fatalln(" Api url couldn't be parsed: check config file")
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting the API: %w", err)
+	}
+	return responseBodyData(resp)
+}
+
+// PollApi queries endpoint, with apiQuery as search query if not empty, and returns the response body.
+func (c *TMDBClient) PollApi(ctx context.Context, endpoint, apiQuery string) ([]byte, error) {
+	v := url.Values{}
 	if apiQuery != "" {
-		v := url.Values{}
 		v.Set("query", apiQuery)
-		parsedUrl.RawQuery = v.Encode()
 	}
-	req, err := http.NewRequest("GET", parsedUrl.String(), nil)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiReadToken)
-	resp, err := http.DefaultClient.Do(req)
-	return HTTPResponseBodyData(resp, err)
+	return c.pollApi(ctx, endpoint, v)
 }
-func PollImgApi(posterPath, apiKey string) []byte {
-	fmt.Printf("󰍉 Downloading poster @ %v", posterPath)
-	fullUrl := formUrl(ImgApiBaseUrl, posterPath)
-	parsedUrl, err := url.Parse(fullUrl)
+
+// pollApi queries endpoint with the parameters in query and returns the response body.
+func (c *TMDBClient) pollApi(ctx context.Context, endpoint string, query url.Values) ([]byte, error) {
+	if !isKnownEndpoint(endpoint) {
+		return nil, fmt.Errorf("unknown endpoint %v", endpoint)
+	}
+	// An empty language in query leaves out the language of c.
+	if !query.Has("language") {
+		query.Set("language", c.Language)
+	}
+	if query.Get("language") == "" {
+		query.Del("language")
+	}
+	if c.Region != "" {
+		query.Set("region", c.Region)
+	}
+	if query.Has("query") {
+		c.logf("󰍉 Searching TMDB.org on %v for %v\n", endpoint, query.Get("query"))
+	} else {
+		c.logf("󰍉 Searching TMDB.org on %v\n", endpoint)
+	}
+	fullUrl, err := formUrl(c.ApiBaseUrl, endpoint, query)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	header.Set("Authorization", "Bearer "+c.ApiReadToken)
+	return c.cachedGet(ctx, fullUrl, fullUrl, header)
+}
+
+// PollImgApi downloads the image at imagePath, in size, e.g. w500 or original.
+func (c *TMDBClient) PollImgApi(ctx context.Context, size, imagePath string) ([]byte, error) {
+	imagePath = path.Join("/", size, imagePath)
+	c.logf("󰍉 Downloading image @ %v\n", imagePath)
+	key, err := formUrl(c.ImgApiBaseUrl, imagePath, nil)
 	if err != nil {
-		log.Fatalln(" Api url couldn't be parsed: check config file")
+		return nil, err
 	}
 	v := url.Values{}
-	v.Set("api_key", apiKey)
-	parsedUrl.RawQuery = v.Encode()
-	resp, err := http.Get(parsedUrl.String())
-	return HTTPResponseBodyData(resp, err)
-}
-func ApiMultiSearch(apiQuery, apiReadToken string) MultiSearchResponse {
-	data := PollApi(SearchMultiEndPoint, apiQuery, apiReadToken)
-	object := new(MultiSearchResponse)
-	if err := json.Unmarshal(data, object); err != nil {
-		log.Fatalln(" Error unpacking the API's response: ", err)
-	}
-	return *object
+	v.Set("api_key", c.ApiKey)
+	fullUrl, err := formUrl(c.ImgApiBaseUrl, imagePath, v)
+	if err != nil {
+		return nil, err
+	}
+	return c.cachedGet(ctx, key, fullUrl, nil)
+}
+
+// ApiMultiSearch searches movies, TV shows and people matching apiQuery.
+func (c *TMDBClient) ApiMultiSearch(ctx context.Context, apiQuery string) (MultiSearchResponse, error) {
+	return c.multiSearch(ctx, apiQuery, c.Language)
+}
+
+// multiSearch is ApiMultiSearch in language, the default of TMDB.org if empty.
+func (c *TMDBClient) multiSearch(ctx context.Context, apiQuery string, language string) (MultiSearchResponse, error) {
+	var object MultiSearchResponse
+	v := url.Values{}
+	v.Set("query", apiQuery)
+	v.Set("language", language)
+	data, err := c.pollApi(ctx, SearchMultiEndPoint, v)
+	if err != nil {
+		return object, err
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return object, &DecodeError{Endpoint: SearchMultiEndPoint, Err: err}
+	}
+	return object, nil
+}
+
+func isKnownEndpoint(endpoint string) bool {
+	for _, pattern := range endpointPatterns {
+		if pattern.MatchString(endpoint) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,403 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/api/apitest"
+)
+
+func TestApiMultiSearch(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	response, err := client.ApiMultiSearch(context.Background(), "Alien")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TotalResults != 4 || len(response.Results) != 4 {
+		t.Fatalf("got %v results, total %v, want 4", len(response.Results), response.TotalResults)
+	}
+	alien := response.Results[0]
+	if alien.ID != 348 || alien.GetTitle() != "Alien" || alien.GetYear() != 1979 || alien.MediaType != api.MediaTypeMovie {
+		t.Errorf("first result is %v, want Alien (1979)", alien.Dump())
+	}
+	if person := response.Results[3]; person.MediaType != api.MediaTypePerson || person.GetYear() != -1 {
+		t.Errorf("last result is %v, want a person without date", person.Dump())
+	}
+}
+
+func TestApiMultiSearchNoResults(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	response, err := client.ApiMultiSearch(context.Background(), "Nothing matches this")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Results) != 0 {
+		t.Errorf("got %v results, want none", len(response.Results))
+	}
+}
+
+func TestGetMedia(t *testing.T) {
+	server := apitest.NewServer(t)
+	client := server.TMDBClient()
+	ctx := context.Background()
+	tests := []struct {
+		mediaType string
+		id        int
+		title     string
+		date      string
+		genres    []string
+		runtime   int
+		imdbID    string
+	}{
+		{api.MediaTypeMovie, 348, "Alien", "1979-05-25", []string{"Horror", "Science Fiction"}, 117, "tt0078748"},
+		// IMDb id from the external ids.
+		{api.MediaTypeTV, 63639, "The Expanse", "2015-12-14", []string{"Action & Adventure", "Drama", "Sci-Fi & Fantasy"}, 43, "tt3230854"},
+	}
+	for _, test := range tests {
+		media, err := client.GetMedia(ctx, test.mediaType, test.id)
+		if err != nil || media.ID != test.id || media.MediaType != test.mediaType || media.GetTitle() != test.title || media.GetReleaseDate() != test.date {
+			t.Errorf("GetMedia(%v, %v) = %v, %v, want %v (%v)", test.mediaType, test.id, media, err, test.title, test.date)
+		}
+		if !slices.Equal(media.GenreNames(), test.genres) || media.GetRuntime() != test.runtime || media.IMDbID != test.imdbID {
+			t.Errorf("GetMedia(%v, %v) has genres %q, runtime %v and IMDb id %q, want %q, %v and %q",
+				test.mediaType, test.id, media.GenreNames(), media.GetRuntime(), media.IMDbID, test.genres, test.runtime, test.imdbID)
+		}
+	}
+	alien, err := client.GetMedia(ctx, api.MediaTypeMovie, 348)
+	if err != nil || alien.Tagline != "In space no one can hear you scream." || alien.VoteAverage != 8.163 || alien.GetOriginalTitle() != "Alien" {
+		t.Errorf("GetMedia(movie, 348) = %+v, %v, want Alien's tagline, vote average and original title", alien, err)
+	}
+	if _, err := client.GetMedia(ctx, api.MediaTypeMovie, 1); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("unknown movie: got %v, want ErrNotFound", err)
+	}
+	if _, err := client.GetMedia(ctx, api.MediaTypePerson, 10205); err == nil {
+		t.Error("person: got no error")
+	}
+}
+
+func TestGetShowAndSeason(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	ctx := context.Background()
+	show, err := client.GetShow(ctx, 63639)
+	if err != nil || show.GetTitle() != "The Expanse" || show.MediaType != api.MediaTypeTV || len(show.Seasons) != 2 {
+		t.Fatalf("GetShow(63639) = %+v, %v, want The Expanse with 2 seasons", show, err)
+	}
+	season, err := client.GetSeason(ctx, 63639, 1)
+	if err != nil || season.SeasonNumber != 1 || len(season.Episodes) != 3 {
+		t.Fatalf("GetSeason(63639, 1) = %+v, %v, want season 1 with 3 episodes", season, err)
+	}
+	first := season.Episodes[0]
+	if err := first.GetStill(ctx, client); err != nil || len(first.StillData) == 0 {
+		t.Errorf("still of %v: got %v bytes, %v", first, len(first.StillData), err)
+	}
+	if _, err := client.GetSeason(ctx, 63639, 2); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("season without fixture: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFindByIMDbID(t *testing.T) {
+	server := apitest.NewServer(t)
+	client := server.TMDBClient()
+	ctx := context.Background()
+	media, err := client.FindByIMDbID(ctx, "tt0078748")
+	if err != nil || media.ID != 348 || media.MediaType != api.MediaTypeMovie {
+		t.Errorf("FindByIMDbID(tt0078748) = %v, %v, want Alien", media, err)
+	}
+	if requests := server.Requests(); !strings.HasSuffix(requests[0], "find/tt0078748?external_source=imdb_id") {
+		t.Errorf("requested %v, want a find by imdb_id", requests[0])
+	}
+	if _, err := client.FindByIMDbID(ctx, "tt0000001"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("unknown IMDb id: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTMDBProvider(t *testing.T) {
+	var provider api.MetadataProvider = apitest.NewServer(t).TMDBClient()
+	ctx := context.Background()
+	results, err := provider.Search(ctx, "Alien")
+	if err != nil || len(results) != 3 {
+		t.Errorf("Search(Alien) gave %v results, %v, want the 3 that aren't people", len(results), err)
+	}
+	alien, err := provider.Details(ctx, api.Media{IMDbID: "tt0078748"})
+	if err != nil || alien.ID != 348 || alien.Runtime != 117 {
+		t.Errorf("Details(tt0078748) = %v, %v, want the details of Alien", alien, err)
+	}
+	if _, err := provider.Details(ctx, api.Media{}); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Details without id: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := apitest.NewServer(t)
+	ctx := context.Background()
+
+	client := server.TMDBClient()
+	client.ApiReadToken = "wrong"
+	if _, err := client.ApiMultiSearch(ctx, "Alien"); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("search with wrong token: got %v, want ErrUnauthorized", err)
+	}
+	var apiErr *api.APIError
+	if _, err := client.ApiMultiSearch(ctx, "Alien"); !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Errorf("search with wrong token: got %v, want an APIError with status 401", err)
+	}
+
+	client = server.TMDBClient()
+	if _, err := client.PollApi(ctx, "movie/1/credits", ""); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("unknown movie credits: got %v, want ErrNotFound", err)
+	}
+	if _, err := client.PollApi(ctx, "person/1", ""); err == nil {
+		t.Error("unknown endpoint: got no error")
+	}
+	if _, err := client.PollImgApi(ctx, "w500", "/missing.jpg"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("missing image: got %v, want ErrNotFound", err)
+	}
+
+	garbage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "not json")
+	}))
+	defer garbage.Close()
+	client.ApiBaseUrl = garbage.URL
+	var decodeErr *api.DecodeError
+	if _, err := client.ApiMultiSearch(ctx, "Alien"); !errors.As(err, &decodeErr) {
+		t.Errorf("garbage response: got %v, want a DecodeError", err)
+	}
+}
+
+func TestGetDirector(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	ctx := context.Background()
+	tests := []struct {
+		media api.Media
+		want  string
+	}{
+		{api.Media{ID: 348, MediaType: api.MediaTypeMovie}, "Ridley Scott"},
+		{api.Media{ID: 949, MediaType: api.MediaTypeMovie}, "Michael Mann"},
+		{api.Media{ID: 9999001, MediaType: api.MediaTypeMovie}, ""},
+		// Director of most episodes.
+		{api.Media{ID: 63639, MediaType: api.MediaTypeTV}, "Jeff Woolnough"},
+		{api.Media{ID: 348, MediaType: api.MediaTypePerson, Director: "stale"}, ""},
+	}
+	for _, test := range tests {
+		if err := test.media.GetCredits(ctx, client); err != nil {
+			t.Errorf("GetCredits(%v): %v", test.media, err)
+		}
+		if test.media.Director != test.want {
+			t.Errorf("GetCredits(%v) director = %q, want %q", test.media, test.media.Director, test.want)
+		}
+	}
+	missing := api.Media{ID: 1, MediaType: api.MediaTypeMovie}
+	if err := missing.GetCredits(ctx, client); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("GetCredits of unknown movie: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetCredits(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	ctx := context.Background()
+	tests := []struct {
+		media api.Media
+		want  []api.Credit
+	}{
+		{api.Media{ID: 348, MediaType: api.MediaTypeMovie}, []api.Credit{
+			{PersonID: 10205, Name: "Sigourney Weaver", Role: api.RoleCast, Character: "Ellen Ripley", Order: 0},
+			{PersonID: 4139, Name: "Tom Skerritt", Role: api.RoleCast, Character: "Dallas", Order: 1},
+			{PersonID: 5047, Name: "John Hurt", Role: api.RoleCast, Character: "Kane", Order: 2},
+			{PersonID: 578, Name: "Ridley Scott", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 1723, Name: "Dan O'Bannon", Role: api.RoleWriter, Job: "Screenplay"},
+			{PersonID: 1760, Name: "Jerry Goldsmith", Role: api.RoleComposer, Job: "Original Music Composer"},
+		}},
+		{api.Media{ID: 63639, MediaType: api.MediaTypeTV}, []api.Credit{
+			{PersonID: 1118387, Name: "Steven Strait", Role: api.RoleCast, Character: "James Holden", Order: 0},
+			{PersonID: 19143, Name: "Thomas Jane", Role: api.RoleCast, Character: "Josephus Miller / Miller (Hallucination)", Order: 1},
+			{PersonID: 41671, Name: "Mark Fergus", Role: api.RoleWriter, Job: "Writer"},
+			{PersonID: 87549, Name: "Breck Eisner", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 66714, Name: "Jeff Woolnough", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 1015909, Name: "Clinton Shorter", Role: api.RoleComposer, Job: "Original Music Composer"},
+		}},
+	}
+	for _, test := range tests {
+		if err := test.media.GetCredits(ctx, client); err != nil {
+			t.Fatalf("GetCredits(%v): %v", test.media, err)
+		}
+		if !slices.Equal(test.media.Credits, test.want) {
+			t.Errorf("GetCredits(%v) = %+v, want %+v", test.media, test.media.Credits, test.want)
+		}
+	}
+}
+
+func TestGetPoster(t *testing.T) {
+	client := apitest.NewServer(t).TMDBClient()
+	ctx := context.Background()
+	want, err := os.ReadFile("apitest/testdata/t/p/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := api.Media{ID: 348, PosterPath: "/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg"}
+	if err := media.GetPoster(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(media.PosterData, want) {
+		t.Errorf("got %v bytes of poster, want the %v bytes of the recorded one", len(media.PosterData), len(want))
+	}
+	noPoster := api.Media{ID: 9999001}
+	if err := noPoster.GetPoster(ctx, client); err != nil || noPoster.PosterData != nil {
+		t.Errorf("media without poster: got %v bytes and error %v, want nothing", len(noPoster.PosterData), err)
+	}
+}
+
+func TestArtwork(t *testing.T) {
+	server := apitest.NewServer(t)
+	client := server.TMDBClient()
+	client.PosterSize, client.BackdropSize = "w342", "original"
+	ctx := context.Background()
+	tests := []struct {
+		language string
+		logo     string
+	}{
+		{"", "/alienLogoEn.png"},
+		{"fr-FR", "/alienLogoFr.png"},
+		// No German logo, the English one will do.
+		{"de", "/alienLogoEn.png"},
+	}
+	for _, test := range tests {
+		client.Language = test.language
+		alien, err := client.GetMedia(ctx, api.MediaTypeMovie, 348)
+		if err != nil || alien.LogoPath != test.logo {
+			t.Errorf("GetMedia(movie, 348) in %q has logo %q (error %v), want %q", test.language, alien.LogoPath, err, test.logo)
+		}
+	}
+	alien, err := client.GetMedia(ctx, api.MediaTypeMovie, 348)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Artwork(ctx, &alien); err != nil {
+		t.Fatal(err)
+	}
+	if len(alien.PosterData) == 0 || len(alien.Artwork) != 2 {
+		t.Fatalf("got %v bytes of poster and artwork %+v, want a poster, a backdrop and a logo", len(alien.PosterData), alien.Artwork)
+	}
+	if a := alien.Artwork[0]; a.Kind != api.ArtworkBackdrop || a.Size != "original" || a.Mime != "image/jpeg" || len(a.Data) == 0 {
+		t.Errorf("got backdrop %v %v %v of %v bytes", a.Kind, a.Size, a.Mime, len(a.Data))
+	}
+	if a := alien.Artwork[1]; a.Kind != api.ArtworkLogo || a.Size != "w500" || a.Mime != "image/png" || len(a.Data) == 0 {
+		t.Errorf("got logo %v %v %v of %v bytes", a.Kind, a.Size, a.Mime, len(a.Data))
+	}
+	for _, want := range []string{"/t/p/w342/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg", "/t/p/original/AmR3JG1VQVxU8TfAvljUhfSFUOx.jpg", "/t/p/w500/alienLogoEn.png"} {
+		if !slices.ContainsFunc(server.Requests(), func(r string) bool { return strings.HasPrefix(r, want+"?") }) {
+			t.Errorf("didn't request %v, requested %q", want, server.Requests())
+		}
+	}
+
+	// Sizes set to none aren't downloaded.
+	client.BackdropSize, client.LogoSize = api.NoArtwork, api.NoArtwork
+	alien.Artwork = nil
+	if err := client.Artwork(ctx, &alien); err != nil || len(alien.Artwork) != 0 {
+		t.Errorf("got artwork %+v, %v, want none", alien.Artwork, err)
+	}
+}
+
+// mapCache is an api.Cache ignoring maxAge.
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string, maxAge time.Duration) ([]byte, bool, error) {
+	data, found := c[key]
+	return data, found, nil
+}
+
+func (c mapCache) Set(key string, data []byte) error {
+	c[key] = data
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	server := apitest.NewServer(t)
+	client := server.TMDBClient()
+	cache := mapCache{}
+	client.Cache = cache
+	ctx := context.Background()
+	for range 2 {
+		if _, err := client.ApiMultiSearch(ctx, "Alien"); err != nil {
+			t.Fatal(err)
+		}
+		media := api.Media{ID: 348, PosterPath: "/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg"}
+		if err := media.GetPoster(ctx, client); err != nil {
+			t.Fatal(err)
+		}
+		if len(media.PosterData) == 0 {
+			t.Error("got no poster")
+		}
+	}
+	if requests := server.Requests(); len(requests) != 2 {
+		t.Errorf("server got %v, want a single search and a single poster", requests)
+	}
+	for key := range cache {
+		if strings.Contains(key, apitest.ApiKey) {
+			t.Errorf("cache key %v holds the api key", key)
+		}
+	}
+
+	client.Refresh = true
+	if _, err := client.ApiMultiSearch(ctx, "Alien"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := server.Requests(); len(requests) != 3 {
+		t.Errorf("server got %v, want the search again on refresh", requests)
+	}
+	if _, err := client.PollApi(ctx, "movie/1/credits", ""); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if len(cache) != 2 {
+		t.Errorf("cached %v responses, want errors left out", len(cache))
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	server := apitest.NewServer(t)
+	client := server.TMDBClient()
+	client.Language, client.Region = "fr", "FR"
+	ctx := context.Background()
+	alien, err := client.GetMedia(ctx, api.MediaTypeMovie, 348)
+	if err != nil || alien.GetTitle() != "Alien, le huitième passager" || alien.GetOriginalTitle() != "Alien" ||
+		!strings.HasPrefix(alien.Overview, "Durant le voyage") || !strings.HasPrefix(alien.OriginalOverview, "During its return") {
+		t.Errorf("GetMedia(movie, 348) in French = %v, %v, want French title and overview, and English original ones", alien.Dump(), err)
+	}
+	if want := "/3/movie/348?append_to_response=translations%2Cimages&include_image_language=fr%2Cen%2Cnull&language=fr&region=FR"; !slices.Contains(server.Requests(), want) {
+		t.Errorf("requested %q, want %q", server.Requests(), want)
+	}
+	// Without translations, there is no original overview.
+	heat, err := client.GetMedia(ctx, api.MediaTypeMovie, 949)
+	if err != nil || heat.OriginalOverview != "" || heat.Overview == "" {
+		t.Errorf("GetMedia(movie, 949) in French = %v, %v, want the default overview without translations", heat.Dump(), err)
+	}
+
+	tests := []struct {
+		title string
+		want  string
+		// requests are the searches sent, in order.
+		requests []string
+	}{
+		{"Alien", "Alien, le huitième passager", []string{"/3/search/multi?language=fr&query=Alien&region=FR"}},
+		// Nothing in French, retried without the language and found by original title.
+		{"Heat", "Heat", []string{"/3/search/multi?language=fr&query=Heat&region=FR", "/3/search/multi?query=Heat&region=FR"}},
+	}
+	for _, test := range tests {
+		before := len(server.Requests())
+		results, err := client.Search(ctx, test.title)
+		if err != nil || len(results) == 0 || results[0].GetTitle() != test.want {
+			t.Errorf("Search(%v) in French = %v, %v, want %v first", test.title, results, err, test.want)
+		}
+		if requests := server.Requests()[before:]; !slices.Equal(requests, test.requests) {
+			t.Errorf("Search(%v) in French requested %q, want %q", test.title, requests, test.requests)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package apitest provides a fake TMDB.org and a fake OMDb serving recorded responses, for tests.
+package apitest
+
+import (
+	"embed"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+const (
+	ApiReadToken = "test-read-token"
+	ApiKey       = "test-api-key"
+	// ApiPrefix and ImgApiPrefix are the url paths the api and the image api are served on.
+	ApiPrefix    = "/3"
+	ImgApiPrefix = "/t/p"
+	OMDbApiKey   = "test-omdb-key"
+)
+
+// testdata mirrors the url paths of the api.
+// Searches are served from testdata/3/search/multi/<lowercased query>.json,
+// other api endpoints from testdata/3/<endpoint>.json and images of any size from testdata/t/p/<file>.
+// With a language, <file>.<language>.json is served instead: searches without one find nothing, other endpoints keep the default file.
+//
+//go:embed testdata
+var testdata embed.FS
+
+// recorder records the requests of a fake api.
+type recorder struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (rec *recorder) record(r *http.Request) {
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, r.URL.RequestURI())
+	rec.mu.Unlock()
+}
+
+// Requests returns the url paths, with query, requested so far.
+func (rec *recorder) Requests() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.requests...)
+}
+
+// Server is a fake TMDB.org.
+// Unknown searches get no results, other unknown urls get a 404.
+type Server struct {
+	*httptest.Server
+	recorder
+}
+
+// NewServer starts a fake TMDB.org, closed when t ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// TMDBClient returns a client of s, logging nothing, without rate limit and with short backoffs.
+func (s *Server) TMDBClient() *api.TMDBClient {
+	client := api.NewTMDBClient(ApiReadToken, ApiKey)
+	client.ApiBaseUrl = s.URL + ApiPrefix
+	client.ImgApiBaseUrl = s.URL + ImgApiPrefix
+	client.HTTPClient = s.Client()
+	client.Logger = log.New(io.Discard, "", 0)
+	client.Limiter = nil
+	client.BaseBackoff = time.Millisecond
+	client.MaxBackoff = 10 * time.Millisecond
+	return client
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, ApiPrefix+"/"):
+		if r.Header.Get("Authorization") != "Bearer "+ApiReadToken {
+			writeStatus(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+			return
+		}
+		file := r.URL.Path
+		if r.URL.Path == path.Join(ApiPrefix, api.SearchMultiEndPoint) {
+			file = path.Join(r.URL.Path, strings.ToLower(r.URL.Query().Get("query")))
+		}
+		data, err := fs.ReadFile(testdata, path.Join("testdata", file+".json"))
+		if language := r.URL.Query().Get("language"); language != "" {
+			localized, localizedErr := fs.ReadFile(testdata, path.Join("testdata", file+"."+language+".json"))
+			if localizedErr == nil || r.URL.Path == path.Join(ApiPrefix, api.SearchMultiEndPoint) {
+				data, err = localized, localizedErr
+			}
+		}
+		if err != nil {
+			if r.URL.Path == path.Join(ApiPrefix, api.SearchMultiEndPoint) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"page":1,"results":[],"total_pages":1,"total_results":0}`)
+				return
+			}
+			writeStatus(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case strings.HasPrefix(r.URL.Path, ImgApiPrefix+"/"):
+		if r.URL.Query().Get("api_key") != ApiKey {
+			writeStatus(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+			return
+		}
+		data, err := fs.ReadFile(testdata, path.Join("testdata", ImgApiPrefix, path.Base(r.URL.Path)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeStatus answers like TMDB.org does on errors.
+func writeStatus(w http.ResponseWriter, code int, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":        false,
+		"status_code":    statusCode,
+		"status_message": message,
+	})
+}
+
+// OMDbServer is a fake OMDb.
+// Searches are served from testdata/omdb/s/<lowercased query>.json, titles from testdata/omdb/i/<IMDb id>.json
+// and posters from testdata/omdb/posters, with {{server}} in responses replaced by the url of the server.
+// Unknown searches and titles get OMDb's errors.
+type OMDbServer struct {
+	*httptest.Server
+	recorder
+}
+
+// NewOMDbServer starts a fake OMDb, closed when t ends.
+func NewOMDbServer(t testing.TB) *OMDbServer {
+	s := &OMDbServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// OMDbClient returns a client of s, logging nothing, without rate limit and with short backoffs.
+func (s *OMDbServer) OMDbClient() *api.OMDbClient {
+	client := api.NewOMDbClient(OMDbApiKey)
+	client.ApiBaseUrl = s.URL + "/"
+	client.HTTPClient = s.Client()
+	client.Logger = log.New(io.Discard, "", 0)
+	client.Limiter = nil
+	client.BaseBackoff = time.Millisecond
+	client.MaxBackoff = 10 * time.Millisecond
+	return client
+}
+
+func (s *OMDbServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+	if strings.HasPrefix(r.URL.Path, "/posters/") {
+		data, err := fs.ReadFile(testdata, path.Join("testdata/omdb", r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	if query.Get("apikey") != OMDbApiKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"Response":"False","Error":"Invalid API key!"}`)
+		return
+	}
+	var file, notFound string
+	switch {
+	case query.Has("s"):
+		file, notFound = path.Join("s", strings.ToLower(query.Get("s"))+".json"), "Movie not found!"
+	case query.Has("i"):
+		file, notFound = path.Join("i", query.Get("i")+".json"), "Incorrect IMDb ID."
+	default:
+		io.WriteString(w, `{"Response":"False","Error":"Something went wrong."}`)
+		return
+	}
+	data, err := fs.ReadFile(testdata, path.Join("testdata/omdb", file))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Response": "False", "Error": notFound})
+		return
+	}
+	io.WriteString(w, strings.ReplaceAll(string(data), "{{server}}", s.URL))
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Kinds of artwork, besides the poster in Media.PosterData.
+const (
+	ArtworkBackdrop = "backdrop"
+	ArtworkLogo     = "logo"
+	// ArtworkThumbnail is a small copy of the poster, made locally for previews.
+	ArtworkThumbnail = "thumbnail"
+)
+
+// NoArtwork as the size of a kind of artwork leaves it out of downloads.
+const NoArtwork = "none"
+
+// ArtworkSizes are the sizes of the images downloaded from TMDB.org, e.g. w500 or original.
+// See https://developer.themoviedb.org/reference/configuration-details for the sizes of each kind.
+type ArtworkSizes struct {
+	PosterSize   string
+	BackdropSize string
+	LogoSize     string
+	StillSize    string
+}
+
+// DefaultArtworkSizes are the sizes of a new TMDBClient.
+var DefaultArtworkSizes = ArtworkSizes{PosterSize: "w500", BackdropSize: "w1280", LogoSize: "w500", StillSize: "w300"}
+
+// Artwork is an image of a media, other than its poster.
+type Artwork struct {
+	Kind string
+	// Size is the TMDB.org size of the image, e.g. w1280, or its width for a thumbnail, e.g. w200.
+	Size string
+	Mime string
+	Data []byte
+}
+
+// Images are the images of a media, given by the details endpoints when appended.
+// Only logos are read, the details give the path of the poster and backdrop.
+type Images struct {
+	Logos []Image `json:"logos"`
+}
+
+// Image is an image of a media, in a language if it has text.
+type Image struct {
+	FilePath string `json:"file_path"`
+	Language string `json:"iso_639_1"`
+}
+
+// setLogo sets m.LogoPath to the path of the logo of m in language, an ISO 639-1 code, or else in English, or else its first logo.
+// TMDB.org lists logos best rated first.
+func (m *Media) setLogo(language string) {
+	for _, l := range []string{language, "en"} {
+		for _, logo := range m.Images.Logos {
+			if l != "" && logo.Language == l {
+				m.LogoPath = logo.FilePath
+				return
+			}
+		}
+	}
+	if len(m.Images.Logos) > 0 {
+		m.LogoPath = m.Images.Logos[0].FilePath
+	}
+}
+
+// GetArtwork downloads the backdrop and logo of m into m.Artwork, those it has and whose size isn't NoArtwork.
+func (m *Media) GetArtwork(ctx context.Context, c *TMDBClient) error {
+	for _, a := range []struct{ kind, size, path string }{
+		{ArtworkBackdrop, c.BackdropSize, m.BackdropPath},
+		{ArtworkLogo, c.LogoSize, m.LogoPath},
+	} {
+		if a.path == "" || a.size == NoArtwork {
+			continue
+		}
+		data, err := c.PollImgApi(ctx, a.size, a.path)
+		if err != nil {
+			return err
+		}
+		m.Artwork = append(m.Artwork, Artwork{Kind: a.kind, Size: a.size, Mime: ImageMime(a.path, data), Data: data})
+		c.logf("✓ Downloaded %v for %v\n", a.kind, m)
+	}
+	return nil
+}
+
+// imageMimes are the mime types of the image files of TMDB.org, by extension.
+var imageMimes = map[string]string{".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".png": "image/png", ".svg": "image/svg+xml", ".webp": "image/webp"}
+
+// ImageMime returns the mime type of the image data at imagePath, from its extension or else its content.
+func ImageMime(imagePath string, data []byte) string {
+	if mime, ok := imageMimes[strings.ToLower(path.Ext(imagePath))]; ok {
+		return mime
+	}
+	return http.DetectContentType(data)
+}
+
+// ImageExt returns the extension of the image files of mime type, e.g. .jpg, or an empty one if it's unknown.
+func ImageExt(mime string) string {
+	switch mime {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached responses are used for.
+const DefaultCacheTTL = 7 * 24 * time.Hour
+
+// Cache stores api responses by key.
+type Cache interface {
+	// Get returns the data stored under key if it was stored less than maxAge ago.
+	Get(key string, maxAge time.Duration) (data []byte, found bool, err error)
+	Set(key string, data []byte) error
+}
+
+// cachedGet returns the response to rawUrl stored in c.Cache under key,
+// requesting rawUrl and storing the response if there's none or c.Refresh is set.
+// Cache errors are logged, they don't fail the request.
+func (c *Requester) cachedGet(ctx context.Context, key string, rawUrl string, header http.Header) ([]byte, error) {
+	if c.Cache == nil {
+		return c.get(ctx, rawUrl, header)
+	}
+	if !c.Refresh {
+		data, found, err := c.Cache.Get(key, c.CacheTTL)
+		if err != nil {
+			c.logf(" Couldn't read the response cache: %v\n", err)
+		} else if found {
+			return data, nil
+		}
+	}
+	data, err := c.get(ctx, rawUrl, header)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Cache.Set(key, data); err != nil {
+		c.logf(" Couldn't write the response cache: %v\n", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const CrewJobDirector = "Director"
+
+// Roles of the credits kept in the library.
+const (
+	RoleCast     = "cast"
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+	RoleComposer = "composer"
+)
+
+// TopBilledCast is how many cast members of a media are kept, in billing order.
+const TopBilledCast = 10
+
+// crewRoles maps the crew jobs kept in the library to their role.
+var crewRoles = map[string]string{
+	CrewJobDirector:           RoleDirector,
+	"Screenplay":              RoleWriter,
+	"Writer":                  RoleWriter,
+	"Story":                   RoleWriter,
+	"Teleplay":                RoleWriter,
+	"Novel":                   RoleWriter,
+	"Original Music Composer": RoleComposer,
+	"Music":                   RoleComposer,
+	"Composer":                RoleComposer,
+}
+
+// Credit is the work of a person on a media.
+type Credit struct {
+	PersonID int
+	Name     string
+	Role     string
+	// Job is the crew job, empty for the cast.
+	Job string
+	// Character is the part played by the cast.
+	Character string
+	// Order is the billing order of the cast.
+	Order int
+}
+
+func (c Credit) String() string {
+	switch {
+	case c.Role != RoleCast:
+		return c.Job
+	case c.Character != "":
+		return "as " + c.Character
+	}
+	return RoleCast
+}
+
+// MediaCredits is the response of the movie/{id}/credits endpoint.
+type MediaCredits struct {
+	Cast []CastMember `json:"cast"`
+	Crew []CrewMember `json:"crew"`
+}
+
+type CastMember struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Character string `json:"character"`
+	Order     int    `json:"order"`
+}
+
+type CrewMember struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Department string `json:"department"`
+	Job        string `json:"job"`
+}
+
+// AggregateCredits is the response of the tv/{id}/aggregate_credits endpoint, the credits of all the episodes of a TV show.
+type AggregateCredits struct {
+	Cast []AggregateCastMember `json:"cast"`
+	Crew []AggregateCrewMember `json:"crew"`
+}
+
+type AggregateCastMember struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+	Roles []struct {
+		Character    string `json:"character"`
+		EpisodeCount int    `json:"episode_count"`
+	} `json:"roles"`
+}
+
+type AggregateCrewMember struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Department string `json:"department"`
+	Jobs       []struct {
+		Job          string `json:"job"`
+		EpisodeCount int    `json:"episode_count"`
+	} `json:"jobs"`
+}
+
+// GetCredits downloads the credits of m into m.Credits: its top billed cast, directors, writers and composers.
+// m.Director is set to the first director of a movie, or to the director of most episodes of a TV show.
+// Will silently use an empty string if m has no director, and no credits if m is neither a movie nor a TV show.
+func (m *Media) GetCredits(ctx context.Context, c *TMDBClient) error {
+	m.Director, m.Credits = "", nil
+	var err error
+	switch m.MediaType {
+	case MediaTypeMovie:
+		m.Credits, m.Director, err = c.getMovieCredits(ctx, m.ID)
+	case MediaTypeTV:
+		m.Credits, m.Director, err = c.getShowCredits(ctx, m.ID)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if m.Director == "" {
+		c.logf(" Found no director for %v\n", m)
+	} else {
+		c.logf("✓ Found director %v for %v\n", m.Director, m)
+	}
+	return nil
+}
+
+// getMovieCredits returns the credits of the movie with id, and the name of its first director.
+func (c *TMDBClient) getMovieCredits(ctx context.Context, id int) ([]Credit, string, error) {
+	endpoint := fmt.Sprintf("movie/%v/credits", id)
+	data, err := c.PollApi(ctx, endpoint, "")
+	if err != nil {
+		return nil, "", err
+	}
+	object := &MediaCredits{}
+	if err := json.Unmarshal(data, object); err != nil {
+		return nil, "", &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	var credits []Credit
+	for _, p := range topBilled(object.Cast, func(p CastMember) int { return p.Order }) {
+		credits = append(credits, Credit{PersonID: p.ID, Name: p.Name, Role: RoleCast, Character: p.Character, Order: p.Order})
+	}
+	var director string
+	for _, p := range object.Crew {
+		role, ok := crewRoles[p.Job]
+		if !ok || p.Name == "" {
+			continue
+		}
+		if role == RoleDirector && director == "" {
+			director = p.Name
+		}
+		credits = append(credits, Credit{PersonID: p.ID, Name: p.Name, Role: role, Job: p.Job})
+	}
+	return credits, director, nil
+}
+
+// getShowCredits returns the credits of the TV show with id, and the name of the director of most of its episodes.
+func (c *TMDBClient) getShowCredits(ctx context.Context, id int) ([]Credit, string, error) {
+	endpoint := fmt.Sprintf("tv/%v/aggregate_credits", id)
+	data, err := c.PollApi(ctx, endpoint, "")
+	if err != nil {
+		return nil, "", err
+	}
+	object := &AggregateCredits{}
+	if err := json.Unmarshal(data, object); err != nil {
+		return nil, "", &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	var credits []Credit
+	for _, p := range topBilled(object.Cast, func(p AggregateCastMember) int { return p.Order }) {
+		var characters []string
+		for _, r := range p.Roles {
+			if r.Character != "" {
+				characters = append(characters, r.Character)
+			}
+		}
+		credits = append(credits, Credit{PersonID: p.ID, Name: p.Name, Role: RoleCast, Character: strings.Join(characters, " / "), Order: p.Order})
+	}
+	var director string
+	var directorEpisodes int
+	for _, p := range object.Crew {
+		for _, j := range p.Jobs {
+			role, ok := crewRoles[j.Job]
+			if !ok || p.Name == "" {
+				continue
+			}
+			if role == RoleDirector && j.EpisodeCount > directorEpisodes {
+				director, directorEpisodes = p.Name, j.EpisodeCount
+			}
+			credits = append(credits, Credit{PersonID: p.ID, Name: p.Name, Role: role, Job: j.Job})
+		}
+	}
+	return credits, director, nil
+}
+
+// topBilled returns the first TopBilledCast members of cast, in billing order.
+func topBilled[T any](cast []T, order func(T) int) []T {
+	cast = slices.Clone(cast)
+	slices.SortStableFunc(cast, func(a, b T) int { return order(a) - order(b) })
+	return cast[:min(len(cast), TopBilledCast)]
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetMedia fetches the details of the movie or TV show with id, depending on mediaType.
+func (c *TMDBClient) GetMedia(ctx context.Context, mediaType string, id int) (Media, error) {
+	var media Media
+	if mediaType != MediaTypeMovie && mediaType != MediaTypeTV {
+		return media, fmt.Errorf("can't get details of media type %v", mediaType)
+	}
+	endpoint := fmt.Sprintf("%v/%v", mediaType, id)
+	data, err := c.pollApi(ctx, endpoint, c.detailsQuery(mediaType))
+	if err != nil {
+		return media, err
+	}
+	if err := json.Unmarshal(data, &media); err != nil {
+		return media, &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	// Details don't tell the media type, unlike searches.
+	media.MediaType = mediaType
+	media.setIMDbID()
+	media.setTranslations()
+	media.setLogo(c.languageCode())
+	return media, nil
+}
+
+// detailsQuery returns the query of the details endpoint of mediaType, with the translations and images of the media.
+// TV show details don't give the IMDb id unless asked for their external ids.
+// Images are those in the language of c, in English or without text.
+func (c *TMDBClient) detailsQuery(mediaType string) url.Values {
+	v := url.Values{}
+	if mediaType == MediaTypeTV {
+		v.Set("append_to_response", "external_ids,translations,images")
+	} else {
+		v.Set("append_to_response", "translations,images")
+	}
+	languages := "en,null"
+	if code := c.languageCode(); code != "" && code != "en" {
+		languages = code + "," + languages
+	}
+	v.Set("include_image_language", languages)
+	return v
+}
+
+// languageCode returns the ISO 639-1 code of the language of c, e.g. fr for fr-FR.
+func (c *TMDBClient) languageCode() string {
+	code, _, _ := strings.Cut(c.Language, "-")
+	return code
+}
+
+// setIMDbID sets m.IMDbID from the external ids of m, if details didn't give it.
+func (m *Media) setIMDbID() {
+	if m.IMDbID == "" {
+		m.IMDbID = m.ExternalIDs.IMDbID
+	}
+}
+
+// Translations are the translations of a media, given by the details endpoints when appended.
+type Translations struct {
+	Translations []Translation `json:"translations"`
+}
+
+// Translation is the overview and title of a media in a language, empty if they aren't translated.
+type Translation struct {
+	Language string `json:"iso_639_1"`
+	Region   string `json:"iso_3166_1"`
+	Data     struct {
+		Title    string `json:"title"`
+		Name     string `json:"name"`
+		Overview string `json:"overview"`
+	} `json:"data"`
+}
+
+// translation returns the translation of m in language, an ISO 639-1 code, if it has one.
+func (m Media) translation(language string) (Translation, bool) {
+	for _, t := range m.Translations.Translations {
+		if t.Language == language {
+			return t, true
+		}
+	}
+	return Translation{}, false
+}
+
+// setTranslations sets m.OriginalOverview from the translation of m in its original language.
+// An overview missing from the language of the request is read from the English translation, or the original one.
+func (m *Media) setTranslations() {
+	if original, ok := m.translation(m.OriginalLanguage); ok {
+		m.OriginalOverview = original.Data.Overview
+	}
+	if m.Overview != "" {
+		return
+	}
+	if english, ok := m.translation("en"); ok && english.Data.Overview != "" {
+		m.Overview = english.Data.Overview
+	} else {
+		m.Overview = m.OriginalOverview
+	}
+}
+
+// FindResponse lists the media matching an external id.
+type FindResponse struct {
+	MovieResults []Media `json:"movie_results"`
+	TVResults    []Media `json:"tv_results"`
+}
+
+// FindByIMDbID finds the movie or TV show with IMDb id imdbID, e.g. tt0078748.
+// Returns an error matching ErrNotFound if TMDB.org doesn't know it.
+func (c *TMDBClient) FindByIMDbID(ctx context.Context, imdbID string) (Media, error) {
+	endpoint := "find/" + imdbID
+	v := url.Values{}
+	v.Set("external_source", "imdb_id")
+	data, err := c.pollApi(ctx, endpoint, v)
+	if err != nil {
+		return Media{}, err
+	}
+	var object FindResponse
+	if err := json.Unmarshal(data, &object); err != nil {
+		return Media{}, &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	switch {
+	case len(object.MovieResults) > 0:
+		media := object.MovieResults[0]
+		media.MediaType = MediaTypeMovie
+		return media, nil
+	case len(object.TVResults) > 0:
+		media := object.TVResults[0]
+		media.MediaType = MediaTypeTV
+		return media, nil
+	}
+	return Media{}, fmt.Errorf("IMDb id %v: %w", imdbID, ErrNotFound)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError is returned when the API answers with an error status.
+// It matches ErrNotFound, ErrUnauthorized or ErrRateLimited with errors.Is, depending on StatusCode.
+type APIError struct {
+	StatusCode int
+	Url        string
+	// Message is the status_message of the response, or its raw body.
+	Message string
+	// RetryAfter is how long the api asked to wait before retrying, if it did.
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if resp.Request != nil {
+		// Drop the query, it may hold the api key.
+		u := *resp.Request.URL
+		u.RawQuery = ""
+		e.Url = u.String()
+	}
+	var status struct {
+		StatusMessage string `json:"status_message"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.StatusMessage != "" {
+		e.Message = status.StatusMessage
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api response error %v %v: %v", e.StatusCode, e.Url, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// DecodeError is returned when a response body can't be unpacked.
+type DecodeError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unpacking the API's response from %v: %v", e.Endpoint, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
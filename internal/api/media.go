@@ -1,12 +1,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"slices"
 	"time"
-
-	"github.com/briandowns/spinner"
 )
 
 type Media struct {
@@ -17,14 +15,43 @@ type Media struct {
 	ReleaseDate      string `json:"release_date" validate:"required_without=FirstAirDate,omitempty,datetime=2006-01-02"`
 	FirstAirDate     string `json:"first_air_date" validate:"required_without=ReleaseDate,omitempty,datetime=2006-01-02"`
 	Overview         string `json:"overview"`
+	OriginalOverview string `json:"-"`
 	PosterPath       string `json:"poster_path"`
 	PosterData       []byte
+	BackdropPath     string   `json:"backdrop_path"`
+	LogoPath         string   `json:"-"`
 	OriginalLanguage string   `json:"original_language"`
 	OriginalName     string   `json:"original_name"`
 	OriginalTitle    string   `json:"original_title"`
 	OriginCountry    []string `json:"origin_country"`
 	Adult            bool     `json:"adult"`
 	Director         string
+	Credits          []Credit `json:"-"`
+	// The fields below are only given by the details endpoints, see GetMedia.
+	Genres []Genre `json:"genres"`
+	// Runtime is in minutes, TV shows give EpisodeRunTime instead, see GetRuntime.
+	Runtime        int         `json:"runtime"`
+	EpisodeRunTime []int       `json:"episode_run_time"`
+	VoteAverage    float64     `json:"vote_average"`
+	Tagline        string      `json:"tagline"`
+	IMDbID         string      `json:"imdb_id"`
+	ExternalIDs    ExternalIDs `json:"external_ids"`
+	// Translations are only given when asked for, see setTranslations, which sets OriginalOverview from them.
+	Translations Translations `json:"translations"`
+	// Images are only given when asked for, see setLogo, which sets LogoPath from them.
+	Images Images `json:"images"`
+	// Artwork are the backdrop and logo of the media, once downloaded, see GetArtwork.
+	Artwork []Artwork `json:"-"`
+}
+
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExternalIDs are the ids of a media on other sites. Movie details give the IMDb id directly.
+type ExternalIDs struct {
+	IMDbID string `json:"imdb_id"`
 }
 
 const (
@@ -33,22 +60,22 @@ const (
 	MediaTypeMovie  = "movie"
 )
 
-const CrewJobDirector = "Director"
-
 var MediaTypeIcons = map[string]string{
 	MediaTypePerson: "",
 	MediaTypeTV:     "",
 	MediaTypeMovie:  "󰿏",
 }
 
-func (m Media) GetYear() int {
-	var dateToParse string
+// GetReleaseDate returns m.FirstAirDate if m.ReleaseDate is empty, else returns m.ReleaseDate
+func (m Media) GetReleaseDate() string {
 	if m.ReleaseDate == "" {
-		dateToParse = m.FirstAirDate
-	} else {
-		dateToParse = m.ReleaseDate
+		return m.FirstAirDate
 	}
-	date, err := time.Parse(time.DateOnly, dateToParse)
+	return m.ReleaseDate
+}
+
+func (m Media) GetYear() int {
+	date, err := time.Parse(time.DateOnly, m.GetReleaseDate())
 	if err != nil {
 		return -1
 	}
@@ -60,11 +87,17 @@ func (m Media) String() string {
 }
 func (m Media) Dump() string {
 	m.PosterData = []byte{}
+	m.Translations = Translations{}
+	m.Images = Images{}
 	out, _ := json.MarshalIndent(m, "", "	")
 	return string(out)
 }
 
+// Url returns the page of m on TMDB.org, or on IMDb for media found on OMDb, see IMDbMediaID.
 func (m Media) Url() string {
+	if m.ID < 0 && m.IMDbID != "" {
+		return IMDbBaseUrl + "/title/" + m.IMDbID
+	}
 	return fmt.Sprintf(SiteBaseUrl+"/%v/%v", m.MediaType, m.ID)
 }
 
@@ -76,60 +109,71 @@ func (m Media) GetTitle() string {
 	return m.Title
 }
 
-// getDirector downloads the first director name in movie credits, if m.MediaType is MediaTypeMovie.
-// Will silently use an empty string if m isn't a movie.
-func (m *Media) GetDirector(apiReadToken string) {
-	if m.MediaType != MediaTypeMovie {
-		m.Director = ""
-		return
+// GetOriginalTitle returns m.OriginalName if m.OriginalTitle is empty, else return m.OriginalTitle
+func (m Media) GetOriginalTitle() string {
+	if m.OriginalTitle == "" {
+		return m.OriginalName
 	}
-	endpoint := fmt.Sprintf("movie/%v/credits", m.ID)
-	data := PollApi(endpoint, "", apiReadToken)
-	credits := &MediaCredits{}
-	if err := json.Unmarshal(data, credits); err != nil {
-		m.Director = ""
-		fmt.Println(" Found no director for ", m)
-		return
-	}
-	if len(credits.Crew) == 0 {
-		m.Director = ""
-		fmt.Println(" Found no director for ", m)
-		return
+	return m.OriginalTitle
+}
+
+// GetRuntime returns the runtime of a movie, or the usual runtime of an episode of a TV show, in minutes.
+// Returns 0 if it's unknown.
+func (m Media) GetRuntime() int {
+	if m.Runtime == 0 && len(m.EpisodeRunTime) > 0 {
+		return m.EpisodeRunTime[0]
 	}
-	firstDirectorIndex := slices.IndexFunc(credits.Crew, func(c CrewMember) bool {
-		return c.Job == CrewJobDirector && c.Name != ""
-	})
-	if firstDirectorIndex == -1 {
-		m.Director = ""
-		fmt.Println(" Found no director for ", m)
-		return
+	return m.Runtime
+}
+
+// GenreNames lists the names of the genres of m.
+func (m Media) GenreNames() []string {
+	var names []string
+	for _, g := range m.Genres {
+		names = append(names, g.Name)
 	}
-	m.Director = credits.Crew[firstDirectorIndex].Name
-	fmt.Printf("✓ Found director %v for %v\n", m.Director, m)
+	return names
 }
 
-func (m *Media) GetPoster(apiKey string) {
-	spinner := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
-	spinner.Suffix = " Downloading poster\n"
-	spinner.Start()
-	if m.PosterPath == "" {
-		spinner.FinalMSG = " Tried to get the poster of a media without one\n"
-		spinner.Stop()
-		return
+// SetTitleAndYear sets the title and release date fields matching m.MediaType.
+// The date is set to the first day of year.
+func (m *Media) SetTitleAndYear(title string, year int) {
+	m.SetTitleAndDate(title, fmt.Sprintf("%04d-01-01", year))
+}
+
+// SetTitleAndDate sets the title and release date fields matching m.MediaType.
+func (m *Media) SetTitleAndDate(title string, date string) {
+	if m.MediaType == MediaTypeTV {
+		m.FirstAirDate = date
+		m.Name = title
+	} else {
+		m.ReleaseDate = date
+		m.Title = title
 	}
-	data := PollImgApi(m.PosterPath, apiKey)
-	m.PosterData = data
-	spinner.FinalMSG = "✓ Downloaded poster\n"
-	spinner.Stop()
 }
 
-type MediaCredits struct {
-	Crew []CrewMember
+// SetOriginalTitle sets the original title field matching m.MediaType.
+func (m *Media) SetOriginalTitle(title string) {
+	if m.MediaType == MediaTypeTV {
+		m.OriginalName = title
+	} else {
+		m.OriginalTitle = title
+	}
 }
 
-type CrewMember struct {
-	Job  string
-	Name string
+// GetPoster downloads the poster of m into m.PosterData, if m has one.
+func (m *Media) GetPoster(ctx context.Context, c *TMDBClient) error {
+	if m.PosterPath == "" {
+		c.logf(" Tried to get the poster of a media without one\n")
+		return nil
+	}
+	data, err := c.PollImgApi(ctx, c.PosterSize, m.PosterPath)
+	if err != nil {
+		return err
+	}
+	m.PosterData = data
+	c.logf("✓ Downloaded poster for %v\n", m)
+	return nil
 }
 
 type MultiSearchResponse struct {
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const OMDbApiBaseUrl = "https://www.omdbapi.com/"
+const IMDbBaseUrl = "https://www.imdb.com"
+
+// DefaultOMDbRateLimit is low, OMDb's free keys allow 1000 requests a day.
+const DefaultOMDbRateLimit = 5
+
+// omdbGenres maps the OMDb genres named differently from TMDB.org's, see TMDBGenreIDs.
+var omdbGenres = map[string]string{"Sci-Fi": "Science Fiction", "Film-Noir": "Crime"}
+
+// OMDbClient queries the OMDb api, an IMDb based fallback for the titles TMDB.org lacks.
+// OMDb has no TMDB ids, so its media get ids derived from their IMDb id, see IMDbMediaID.
+// Use NewOMDbClient to get one with sensible defaults, fields can be changed before use.
+type OMDbClient struct {
+	ApiBaseUrl string
+	// ApiKey authenticates calls to ApiBaseUrl.
+	ApiKey string
+	Requester
+}
+
+// NewOMDbClient returns a client for the OMDb api that logs to stdout,
+// with the default OMDb rate limit, retries and timeout.
+func NewOMDbClient(apiKey string) *OMDbClient {
+	return &OMDbClient{ApiBaseUrl: OMDbApiBaseUrl, ApiKey: apiKey, Requester: newRequester(DefaultOMDbRateLimit)}
+}
+
+// omdbMedia is a search result or the details of a title, as given by OMDb.
+// Missing fields are "N/A".
+type omdbMedia struct {
+	Title      string `json:"Title"`
+	Year       string `json:"Year"`
+	Released   string `json:"Released"`
+	Runtime    string `json:"Runtime"`
+	Genre      string `json:"Genre"`
+	Director   string `json:"Director"`
+	Plot       string `json:"Plot"`
+	Poster     string `json:"Poster"`
+	IMDbRating string `json:"imdbRating"`
+	IMDbID     string `json:"imdbID"`
+	Type       string `json:"Type"`
+}
+
+// omdbResponse is the envelope of OMDb responses, which fail with Response "False" and an Error.
+type omdbResponse struct {
+	omdbMedia
+	Search   []omdbMedia `json:"Search"`
+	Response string      `json:"Response"`
+	Error    string      `json:"Error"`
+}
+
+// query requests the api with query, and decodes its response.
+// Returns an error matching ErrNotFound if OMDb knows no title matching query.
+func (c *OMDbClient) query(ctx context.Context, query url.Values) (omdbResponse, error) {
+	var object omdbResponse
+	c.logf("󰍉 Searching OMDb for %v\n", cmp.Or(query.Get("s"), query.Get("i")))
+	key, err := formUrl(c.ApiBaseUrl, "", query)
+	if err != nil {
+		return object, err
+	}
+	query.Set("apikey", c.ApiKey)
+	fullUrl, err := formUrl(c.ApiBaseUrl, "", query)
+	if err != nil {
+		return object, err
+	}
+	data, err := c.cachedGet(ctx, key, fullUrl, nil)
+	if err != nil {
+		return object, err
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return object, &DecodeError{Endpoint: "OMDb", Err: err}
+	}
+	if object.Response == "False" {
+		if strings.Contains(object.Error, "not found") || strings.Contains(object.Error, "Incorrect IMDb ID") {
+			return object, fmt.Errorf("OMDb: %v: %w", object.Error, ErrNotFound)
+		}
+		return object, fmt.Errorf("OMDb: %v", object.Error)
+	}
+	return object, nil
+}
+
+func (c *OMDbClient) Name() string { return "OMDb" }
+
+// Search returns the movies and TV shows matching title. They only have a title, a year and a poster.
+func (c *OMDbClient) Search(ctx context.Context, title string) ([]Media, error) {
+	v := url.Values{}
+	v.Set("s", title)
+	object, err := c.query(ctx, v)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var results []Media
+	for _, o := range object.Search {
+		if m, ok := o.media(); ok {
+			results = append(results, m)
+		}
+	}
+	return results, nil
+}
+
+// Details fetches the details of m by its IMDb id, OMDb doesn't know TMDB ids.
+func (c *OMDbClient) Details(ctx context.Context, m Media) (Media, error) {
+	o, err := c.title(ctx, m)
+	if err != nil {
+		return m, err
+	}
+	details, ok := o.media()
+	if !ok {
+		return m, fmt.Errorf("OMDb: %v is a %v, not a movie nor a TV show: %w", m.IMDbID, o.Type, ErrNotFound)
+	}
+	return details, nil
+}
+
+// title fetches the details of m from OMDb, by its IMDb id.
+func (c *OMDbClient) title(ctx context.Context, m Media) (omdbMedia, error) {
+	if m.IMDbID == "" {
+		return omdbMedia{}, fmt.Errorf("OMDb only finds media by IMDb id: %w", ErrNotFound)
+	}
+	v := url.Values{}
+	v.Set("i", m.IMDbID)
+	v.Set("plot", "full")
+	object, err := c.query(ctx, v)
+	return object.omdbMedia, err
+}
+
+// Credits sets the director of m. OMDb only gives the names of people, not their ids, so m has no credits.
+func (c *OMDbClient) Credits(ctx context.Context, m *Media) error {
+	m.Director, m.Credits = "", nil
+	o, err := c.title(ctx, *m)
+	if err != nil {
+		return err
+	}
+	if directors := omdbList(o.Director); len(directors) > 0 {
+		m.Director = directors[0]
+		c.logf("✓ Found director %v for %v\n", m.Director, m)
+	} else {
+		c.logf(" Found no director for %v\n", m)
+	}
+	return nil
+}
+
+// Artwork downloads the poster of m into m.PosterData, if m has one. OMDb has no other artwork.
+func (c *OMDbClient) Artwork(ctx context.Context, m *Media) error {
+	if m.PosterPath == "" {
+		c.logf(" Tried to get the poster of a media without one\n")
+		return nil
+	}
+	c.logf("󰍉 Downloading poster @ %v\n", m.PosterPath)
+	data, err := c.cachedGet(ctx, m.PosterPath, m.PosterPath, nil)
+	if err != nil {
+		return err
+	}
+	m.PosterData = data
+	c.logf("✓ Downloaded poster for %v\n", m)
+	return nil
+}
+
+// omdbValue returns s, or an empty string if OMDb doesn't know it.
+func omdbValue(s string) string {
+	if s == "N/A" {
+		return ""
+	}
+	return s
+}
+
+// omdbList splits a comma separated list of OMDb.
+func omdbList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(omdbValue(s), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// media returns the media o describes, ok is false if o isn't a movie nor a TV show.
+func (o omdbMedia) media() (m Media, ok bool) {
+	switch o.Type {
+	case "movie":
+		m.MediaType = MediaTypeMovie
+	case "series":
+		m.MediaType = MediaTypeTV
+	default:
+		return m, false
+	}
+	m.ID, m.IMDbID = IMDbMediaID(o.IMDbID), o.IMDbID
+	date := ""
+	if released, err := time.Parse("02 Jan 2006", omdbValue(o.Released)); err == nil {
+		date = released.Format(time.DateOnly)
+	} else if len(o.Year) >= 4 {
+		// Series run from a year to another, e.g. 2015–2022.
+		date = o.Year[:4] + "-01-01"
+	}
+	m.SetTitleAndDate(o.Title, date)
+	m.Overview = omdbValue(o.Plot)
+	m.PosterPath = omdbValue(o.Poster)
+	if runtime, err := strconv.Atoi(strings.TrimSuffix(omdbValue(o.Runtime), " min")); err == nil {
+		if m.MediaType == MediaTypeTV {
+			m.EpisodeRunTime = []int{runtime}
+		} else {
+			m.Runtime = runtime
+		}
+	}
+	m.VoteAverage, _ = strconv.ParseFloat(omdbValue(o.IMDbRating), 64)
+	for _, g := range omdbList(o.Genre) {
+		if name, ok := omdbGenres[g]; ok {
+			g = name
+		}
+		if id, ok := TMDBGenreIDs[g]; ok {
+			m.Genres = append(m.Genres, Genre{ID: id, Name: g})
+		}
+	}
+	return m, true
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/api/apitest"
+)
+
+func TestOMDbSearch(t *testing.T) {
+	client := apitest.NewOMDbServer(t).OMDbClient()
+	results, err := client.Search(context.Background(), "Sorcerer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The game is left out.
+	if len(results) != 2 {
+		t.Fatalf("got %v results, want 2", len(results))
+	}
+	sorcerer := results[0]
+	if sorcerer.ID != -76740 || sorcerer.IMDbID != "tt0076740" || sorcerer.GetTitle() != "Sorcerer" || sorcerer.GetYear() != 1977 || sorcerer.MediaType != api.MediaTypeMovie {
+		t.Errorf("first result is %v, want Sorcerer (1977)", sorcerer.Dump())
+	}
+	if results[1].PosterPath != "" {
+		t.Errorf("got poster %q for N/A", results[1].PosterPath)
+	}
+	if results, err := client.Search(context.Background(), "Nothing matches this"); err != nil || len(results) != 0 {
+		t.Errorf("got %v results and error %v, want none", len(results), err)
+	}
+}
+
+func TestOMDbDetails(t *testing.T) {
+	client := apitest.NewOMDbServer(t).OMDbClient()
+	ctx := context.Background()
+	sorcerer, err := client.Details(ctx, api.Media{IMDbID: "tt0076740"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sorcerer.GetReleaseDate() != "1977-06-24" || sorcerer.Runtime != 121 || sorcerer.VoteAverage != 7.7 ||
+		!slices.Equal(sorcerer.GenreNames(), []string{"Adventure", "Drama", "Thriller"}) || sorcerer.Url() != "https://www.imdb.com/title/tt0076740" {
+		t.Errorf("got %v", sorcerer.Dump())
+	}
+	if err := client.Credits(ctx, &sorcerer); err != nil || sorcerer.Director != "William Friedkin" || sorcerer.Credits != nil {
+		t.Errorf("got director %q and credits %v (error %v), want William Friedkin only", sorcerer.Director, sorcerer.Credits, err)
+	}
+	if err := client.Artwork(ctx, &sorcerer); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("apitest/testdata/omdb/posters/sorcerer.jpg")
+	if err != nil || !slices.Equal(sorcerer.PosterData, want) {
+		t.Errorf("got %v bytes of poster, want %v (error %v)", len(sorcerer.PosterData), len(want), err)
+	}
+
+	show, err := client.Details(ctx, api.Media{IMDbID: "tt3230854"})
+	if err != nil || show.MediaType != api.MediaTypeTV || show.GetTitle() != "The Expanse" || show.GetRuntime() != 60 ||
+		!slices.Equal(show.GenreNames(), []string{"Drama", "Mystery", "Science Fiction"}) {
+		t.Errorf("got %v, %v, want The Expanse", show.Dump(), err)
+	}
+	if err := client.Credits(ctx, &show); err != nil || show.Director != "" {
+		t.Errorf("got director %q (error %v), want none", show.Director, err)
+	}
+
+	for _, m := range []api.Media{{IMDbID: "tt0000001"}, {ID: 348, MediaType: api.MediaTypeMovie}} {
+		if _, err := client.Details(ctx, m); !errors.Is(err, api.ErrNotFound) {
+			t.Errorf("Details(%+v) error is %v, want not found", m, err)
+		}
+	}
+	client.ApiKey = "wrong"
+	if _, err := client.Search(ctx, "Sorcerer"); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("got error %v with a wrong key, want unauthorized", err)
+	}
+}
+
+func TestIMDbMediaID(t *testing.T) {
+	tests := []struct {
+		imdbID string
+		want   int
+	}{
+		{"tt0078748", -78748},
+		{"tt12345678", -12345678},
+		{"0078748", 0},
+		{"tt", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := api.IMDbMediaID(tt.imdbID); got != tt.want {
+			t.Errorf("IMDbMediaID(%q) = %v, want %v", tt.imdbID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetadataProvider is a source of media metadata, like TMDB.org or OMDb.
+// The scan command consumes a primary provider and its fallbacks.
+type MetadataProvider interface {
+	// Name names the provider in logs, e.g. TMDB.org.
+	Name() string
+	// Search returns the movies and TV shows matching title.
+	Search(ctx context.Context, title string) ([]Media, error)
+	// Details fetches the details of m, a search result or a media given by its id, or by its IMDb id only.
+	// Returns an error matching ErrNotFound if the provider doesn't know m.
+	Details(ctx context.Context, m Media) (Media, error)
+	// Credits fetches the credits of m into m.Credits and m.Director.
+	Credits(ctx context.Context, m *Media) error
+	// Artwork downloads the poster of m into m.PosterData, if m has one, and its other artwork into m.Artwork.
+	Artwork(ctx context.Context, m *Media) error
+}
+
+// ShowProvider is a MetadataProvider that knows the seasons and episodes of TV shows.
+type ShowProvider interface {
+	MetadataProvider
+	GetShow(ctx context.Context, id int) (Show, error)
+	GetSeason(ctx context.Context, showID int, seasonNumber int) (Season, error)
+	// GetStill downloads the still of e into e.StillData, if e has one.
+	GetStill(ctx context.Context, e *Episode) error
+}
+
+// TMDBGenreIDs are the ids of the genres of TMDB.org, by English name, for movies and TV shows.
+// Genres are stored by id, so providers without genre ids map theirs to TMDB.org's.
+var TMDBGenreIDs = map[string]int{
+	"Action": 28, "Adventure": 12, "Animation": 16, "Comedy": 35, "Crime": 80, "Documentary": 99, "Drama": 18,
+	"Family": 10751, "Fantasy": 14, "History": 36, "Horror": 27, "Music": 10402, "Mystery": 9648, "Romance": 10749,
+	"Science Fiction": 878, "TV Movie": 10770, "Thriller": 53, "War": 10752, "Western": 37,
+	"Action & Adventure": 10759, "Kids": 10762, "News": 10763, "Reality": 10764, "Sci-Fi & Fantasy": 10765,
+	"Soap": 10766, "Talk": 10767, "War & Politics": 10768,
+}
+
+// IMDbMediaID returns the id of the media with IMDb id imdbID, e.g. tt0078748, for providers without TMDB ids:
+// the negative of its IMDb number, so that it can't be a TMDB id. Returns 0 if imdbID isn't an IMDb id.
+func IMDbMediaID(imdbID string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(imdbID, "tt"))
+	if err != nil || !strings.HasPrefix(imdbID, "tt") || n <= 0 {
+		return 0
+	}
+	return -n
+}
+
+func (c *TMDBClient) Name() string { return "TMDB.org" }
+
+// Search returns the movies and TV shows of a multi search for title, leaving out people.
+// If the search in the language of c has none, it's retried without it, where titles are in their original language.
+func (c *TMDBClient) Search(ctx context.Context, title string) ([]Media, error) {
+	results, err := c.search(ctx, title, c.Language)
+	if err != nil || len(results) > 0 || c.Language == "" {
+		return results, err
+	}
+	c.logf("󰍉 Nothing found in %v, searching %v by original title\n", c.Language, title)
+	return c.search(ctx, title, "")
+}
+
+// search returns the movies and TV shows of a multi search for title in language.
+func (c *TMDBClient) search(ctx context.Context, title string, language string) ([]Media, error) {
+	response, err := c.multiSearch(ctx, title, language)
+	if err != nil {
+		return nil, err
+	}
+	var results []Media
+	for _, m := range response.Results {
+		if m.MediaType == MediaTypeMovie || m.MediaType == MediaTypeTV {
+			results = append(results, m)
+		}
+	}
+	return results, nil
+}
+
+// Details fetches the details of m by its TMDB id, or finds it by its IMDb id first.
+func (c *TMDBClient) Details(ctx context.Context, m Media) (Media, error) {
+	if m.ID <= 0 {
+		if m.IMDbID == "" {
+			return m, fmt.Errorf("media without TMDB nor IMDb id: %w", ErrNotFound)
+		}
+		found, err := c.FindByIMDbID(ctx, m.IMDbID)
+		if err != nil {
+			return m, err
+		}
+		m = found
+	}
+	return c.GetMedia(ctx, m.MediaType, m.ID)
+}
+
+func (c *TMDBClient) Credits(ctx context.Context, m *Media) error { return m.GetCredits(ctx, c) }
+
+func (c *TMDBClient) Artwork(ctx context.Context, m *Media) error {
+	if err := m.GetPoster(ctx, c); err != nil {
+		return err
+	}
+	return m.GetArtwork(ctx, c)
+}
+
+func (c *TMDBClient) GetStill(ctx context.Context, e *Episode) error { return e.GetStill(ctx, c) }
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimit is below the ~50 requests per second TMDB.org tolerates.
+	DefaultRateLimit      = 20
+	DefaultMaxRetries     = 4
+	DefaultRequestTimeout = 15 * time.Second
+	DefaultBaseBackoff    = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// RateLimiter spaces out requests so that at most perSecond of them start every second.
+// It's safe for concurrent use, share one between the clients hitting the same api.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a limiter letting perSecond requests through every second.
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until a request may start, or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	return sleep(ctx, wait)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRetryable tells whether a request failing with err may succeed if tried again:
+// rate limits, server errors, network errors and timeouts of a single attempt.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	// http.Client wraps all its errors in a *url.Error, itself a net.Error: look inside.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns how long to wait before retrying after attempt failed with err:
+// the Retry-After delay if the api gave one, else an exponential backoff from base, capped at max, with jitter.
+// ok is false if the Retry-After delay is longer than max, the request isn't retried then.
+func backoff(attempt int, err error, base, max time.Duration) (d time.Duration, ok bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= max
+	}
+	d = base << attempt
+	if d > max || d <= 0 {
+		d = max
+	}
+	// Equal jitter: half fixed, half random, so concurrent clients don't retry in lockstep.
+	half := d / 2
+	return half + rand.N(half+1), true
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an http date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
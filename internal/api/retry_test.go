@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer answers with the statuses in order, then 200 with an empty search result.
+// headers are sent along the status of the same index.
+func newFlakyServer(t *testing.T, statuses []int, headers []http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		if i < len(statuses) {
+			if i < len(headers) {
+				for key, values := range headers[i] {
+					w.Header()[key] = values
+				}
+			}
+			w.WriteHeader(statuses[i])
+			io.WriteString(w, `{"status_message":"flaky"}`)
+			return
+		}
+		io.WriteString(w, `{"results":[],"total_results":0}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestClient(baseUrl string) *TMDBClient {
+	c := NewTMDBClient("token", "key")
+	c.ApiBaseUrl = baseUrl
+	c.Logger = log.New(io.Discard, "", 0)
+	c.Limiter = nil
+	c.BaseBackoff = time.Millisecond
+	c.MaxBackoff = 5 * time.Millisecond
+	return c
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantErr   error
+		wantCalls int32
+	}{
+		{"success", nil, nil, 1},
+		{"transient errors", []int{503, 502, 429}, nil, 4},
+		{"rate limited", []int{429, 429, 429, 429, 429}, ErrRateLimited, 5},
+		{"not retried", []int{404}, ErrNotFound, 1},
+		{"unauthorized", []int{401}, ErrUnauthorized, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := newFlakyServer(t, test.statuses, nil)
+			_, err := newTestClient(server.URL).ApiMultiSearch(context.Background(), "Alien")
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if got := calls.Load(); got != test.wantCalls {
+				t.Errorf("server was called %v times, want %v", got, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	headers := []http.Header{{"Retry-After": []string{"1"}}}
+	server, calls := newFlakyServer(t, []int{429}, headers)
+	client := newTestClient(server.URL)
+	client.MaxBackoff = 2 * time.Second
+	start := time.Now()
+	if _, err := client.ApiMultiSearch(context.Background(), "Alien"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s of Retry-After", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server was called %v times, want 2", calls.Load())
+	}
+
+	// Longer than MaxBackoff: not retried early.
+	server, calls = newFlakyServer(t, []int{429}, []http.Header{{"Retry-After": []string{"60"}}})
+	if _, err := newTestClient(server.URL).ApiMultiSearch(context.Background(), "Alien"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got error %v, want ErrRateLimited", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("server was called %v times, want 1", calls.Load())
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		io.WriteString(w, `{"results":[],"total_results":0}`)
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL)
+	client.RequestTimeout = 50 * time.Millisecond
+	if _, err := client.ApiMultiSearch(context.Background(), "Alien"); err != nil {
+		t.Errorf("got error %v, want the retry to succeed", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server was called %v times, want 2", calls.Load())
+	}
+}
+
+func TestCanceledContextStopsRetries(t *testing.T) {
+	server, calls := newFlakyServer(t, []int{503, 503, 503, 503, 503}, nil)
+	client := newTestClient(server.URL)
+	client.BaseBackoff = time.Hour
+	client.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ApiMultiSearch(ctx, "Alien"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context's", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("server was called %v times, want 1", calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"Thu, 01 Aug 2024 12:00:10 GMT", 10 * time.Second},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.value, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for range 20 {
+			if got, ok := backoff(attempt, errors.New("network"), base, max); got < want/2 || got > want || !ok {
+				t.Errorf("backoff(%v) = %v, %v, want between %v and %v", attempt, got, ok, want/2, want)
+			}
+		}
+	}
+	retryAfter := &APIError{StatusCode: 429, RetryAfter: 5 * time.Second}
+	if got, ok := backoff(0, retryAfter, base, 10*time.Second); got != 5*time.Second || !ok {
+		t.Errorf("backoff with Retry-After = %v, %v, want 5s", got, ok)
+	}
+	if got, ok := backoff(0, retryAfter, base, max); ok {
+		t.Errorf("backoff with Retry-After longer than max = %v, %v, want no retry", got, ok)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: 429}, true},
+		{&APIError{StatusCode: 503}, true},
+		{&APIError{StatusCode: 404}, false},
+		{fmt.Errorf("contacting the API: %w", &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}), true},
+		{fmt.Errorf("contacting the API: %w", &url.Error{Op: "Get", URL: "http://localhost", Err: context.DeadlineExceeded}), true},
+		{fmt.Errorf("reading the API's response: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("contacting the API: %w", &url.Error{Op: "Get", URL: "ftp://localhost", Err: errors.New("unsupported protocol scheme")}), false},
+		{errors.New(`net/http: invalid method "GET "`), false},
+	}
+	for _, test := range tests {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	ctx := context.Background()
+	start := time.Now()
+	for range 11 {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("11 requests at 100/s went through in %v, want at least 100ms", elapsed)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Show is a TV show with the summary of its seasons, as given by the tv/{id} endpoint.
+type Show struct {
+	Media
+	NumberOfSeasons int      `json:"number_of_seasons"`
+	Seasons         []Season `json:"seasons"`
+}
+
+// Season is a season of a TV show. Its episodes are only given by the tv/{id}/season/{n} endpoint.
+type Season struct {
+	ID           int       `json:"id"`
+	SeasonNumber int       `json:"season_number"`
+	Name         string    `json:"name"`
+	Overview     string    `json:"overview"`
+	AirDate      string    `json:"air_date"`
+	PosterPath   string    `json:"poster_path"`
+	Episodes     []Episode `json:"episodes"`
+}
+
+// Episode is an episode of a TV show season.
+type Episode struct {
+	ID            int    `json:"id"`
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+	Name          string `json:"name"`
+	Overview      string `json:"overview"`
+	AirDate       string `json:"air_date"`
+	StillPath     string `json:"still_path"`
+	StillData     []byte `json:"-"`
+}
+
+func (e Episode) String() string {
+	return fmt.Sprintf("S%02dE%02d «%v»", e.SeasonNumber, e.EpisodeNumber, e.Name)
+}
+
+// GetShow fetches the details of the TV show with id, with the summary of its seasons.
+func (c *TMDBClient) GetShow(ctx context.Context, id int) (Show, error) {
+	var show Show
+	endpoint := fmt.Sprintf("tv/%v", id)
+	data, err := c.pollApi(ctx, endpoint, c.detailsQuery(MediaTypeTV))
+	if err != nil {
+		return show, err
+	}
+	if err := json.Unmarshal(data, &show); err != nil {
+		return show, &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	show.MediaType = MediaTypeTV
+	show.setIMDbID()
+	show.setTranslations()
+	show.setLogo(c.languageCode())
+	return show, nil
+}
+
+// GetSeason fetches season number seasonNumber of the TV show with id showID, with its episodes.
+func (c *TMDBClient) GetSeason(ctx context.Context, showID int, seasonNumber int) (Season, error) {
+	var season Season
+	endpoint := fmt.Sprintf("tv/%v/season/%v", showID, seasonNumber)
+	data, err := c.pollApi(ctx, endpoint, url.Values{})
+	if err != nil {
+		return season, err
+	}
+	if err := json.Unmarshal(data, &season); err != nil {
+		return season, &DecodeError{Endpoint: endpoint, Err: err}
+	}
+	return season, nil
+}
+
+// GetStill downloads the still of e into e.StillData, if e has one.
+func (e *Episode) GetStill(ctx context.Context, c *TMDBClient) error {
+	if e.StillPath == "" {
+		return nil
+	}
+	data, err := c.PollImgApi(ctx, c.StillSize, e.StillPath)
+	if err != nil {
+		return err
+	}
+	e.StillData = data
+	return nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+	"github.com/JeanLeonHenry/mymedia/internal/thumbnail"
+)
+
+// ErrUndecodablePoster is returned by Thumbnail for a poster that isn't a JPEG or PNG image.
+var ErrUndecodablePoster = errors.New("undecodable poster")
+
+// writeArtwork writes a, an image of the media with id mediaID, replacing the one of the same kind and size.
+func writeArtwork(e execer, mediaID int, a api.Artwork) error {
+	sum := sha256.Sum256(a.Data)
+	_, err := e.Exec("INSERT OR REPLACE INTO artwork(media_id, kind, size, mime, bytes, sha256) VALUES(?,?,?,?,?,?)",
+		mediaID, a.Kind, a.Size, a.Mime, a.Data, hex.EncodeToString(sum[:]))
+	return err
+}
+
+// Artwork returns the largest image of kind of the media with id mediaID, and whether it has one.
+func (dbh *DBHandler) Artwork(mediaID int, kind string) (a api.Artwork, found bool, err error) {
+	err = dbh.DB.QueryRow("SELECT kind, size, mime, bytes FROM artwork WHERE media_id=? AND kind=? ORDER BY length(bytes) DESC LIMIT 1", mediaID, kind).
+		Scan(&a.Kind, &a.Size, &a.Mime, &a.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, false, nil
+	}
+	return a, err == nil, err
+}
+
+// ThumbnailSize is the size of the thumbnails width wide in the artwork table, e.g. w200.
+func ThumbnailSize(width int) string {
+	return fmt.Sprintf("w%d", width)
+}
+
+// Thumbnail returns the thumbnail width wide of the poster of the media with id mediaID, or nil if it has no poster.
+// The thumbnail is made on first use and stored until the poster is written again.
+func (dbh *DBHandler) Thumbnail(mediaID int, width int) ([]byte, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("thumbnail width %v isn't positive", width)
+	}
+	var data []byte
+	err := dbh.DB.QueryRow("SELECT bytes FROM artwork WHERE media_id=? AND kind=? AND size=?", mediaID, api.ArtworkThumbnail, ThumbnailSize(width)).Scan(&data)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	poster, err := dbh.Poster(mediaID)
+	if err != nil || len(poster) == 0 {
+		return nil, err
+	}
+	if data, err = thumbnail.Make(poster, width); err != nil {
+		return nil, fmt.Errorf("%w of media %v: %v", ErrUndecodablePoster, mediaID, err)
+	}
+	a := api.Artwork{Kind: api.ArtworkThumbnail, Size: ThumbnailSize(width), Mime: thumbnail.Mime, Data: data}
+	return data, writeArtwork(dbh.DB, mediaID, a)
+}
+
+// MakeThumbnails makes the missing thumbnails width wide of the posters of the db, see Thumbnail.
+// Posters that don't decode are skipped, doctor reports them. Returns how many thumbnails were made.
+func (dbh *DBHandler) MakeThumbnails(width int) (made int, err error) {
+	// Ids are read first, writes wait for reads to end.
+	rows, err := dbh.DB.Query(`SELECT DISTINCT id FROM media WHERE length(poster) > 0
+	AND id NOT IN (SELECT media_id FROM artwork WHERE kind=? AND size=?)`, api.ArtworkThumbnail, ThumbnailSize(width))
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		data, err := dbh.Thumbnail(id, width)
+		if err != nil && !errors.Is(err, ErrUndecodablePoster) {
+			return made, err
+		}
+		if len(data) > 0 {
+			made++
+		}
+	}
+	return made, nil
+}
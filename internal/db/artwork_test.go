@@ -0,0 +1,99 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// testPoster returns a JPEG image 40 pixels wide.
+func testPoster(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, 40, 60)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestArtwork(t *testing.T) {
+	dbh := openTestDB(t)
+	backdrop := api.Artwork{Kind: api.ArtworkBackdrop, Size: "w1280", Mime: "image/jpeg", Data: []byte("backdrop")}
+	logo := api.Artwork{Kind: api.ArtworkLogo, Size: "w500", Mime: "image/png", Data: []byte("logo")}
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", Artwork: []api.Artwork{backdrop, logo}}
+	if _, err := dbh.WriteToDB(alien, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	// Writing without artwork keeps it, writing a kind replaces it.
+	alien.Artwork = nil
+	if _, err := dbh.WriteToDB(alien, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	backdrop = api.Artwork{Kind: api.ArtworkBackdrop, Size: "original", Mime: "image/jpeg", Data: []byte("original backdrop")}
+	alien.Artwork = []api.Artwork{backdrop}
+	if _, err := dbh.WriteToDB(alien, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []api.Artwork{backdrop, logo} {
+		got, found, err := dbh.Artwork(alien.ID, want.Kind)
+		if err != nil || !found || got.Size != want.Size || got.Mime != want.Mime || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("Artwork(%v) = %v %v %q, %v, %v, want %v %v %q", want.Kind, got.Size, got.Mime, got.Data, found, err, want.Size, want.Mime, want.Data)
+		}
+	}
+	var hash string
+	if err := dbh.DB.QueryRow("SELECT sha256 FROM artwork WHERE kind=?", api.ArtworkLogo).Scan(&hash); err != nil || len(hash) != 64 {
+		t.Errorf("got sha256 %q, %v", hash, err)
+	}
+
+	if err := dbh.DeleteMedia(alien.ID, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := dbh.Artwork(alien.ID, api.ArtworkLogo); err != nil || found {
+		t.Errorf("deleted media: found logo %v, %v", found, err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	dbh := openTestDB(t)
+	for _, m := range []api.Media{
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", PosterData: testPoster(t)},
+		{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15", PosterData: []byte("bad")},
+		{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14"},
+	} {
+		if _, err := dbh.WriteToDB(m, "/media/"+m.GetTitle()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	made, err := dbh.MakeThumbnails(10)
+	if err != nil || made != 1 {
+		t.Fatalf("MakeThumbnails(10) = %v, %v, want 1 for Alien", made, err)
+	}
+	if made, err := dbh.MakeThumbnails(10); err != nil || made != 0 {
+		t.Errorf("MakeThumbnails(10) again = %v, %v, want 0", made, err)
+	}
+	thumb, err := dbh.Thumbnail(348, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config, err := jpeg.DecodeConfig(bytes.NewReader(thumb)); err != nil || config.Width != 10 || config.Height != 15 {
+		t.Errorf("got thumbnail %+v, %v, want a 10x15 JPEG", config, err)
+	}
+	if thumb, err := dbh.Thumbnail(63639, 10); err != nil || thumb != nil {
+		t.Errorf("Thumbnail without poster = %v, %v, want nothing", thumb, err)
+	}
+	if _, err := dbh.Thumbnail(949, 10); !errors.Is(err, ErrUndecodablePoster) {
+		t.Errorf("Thumbnail of a bad poster: got %v, want ErrUndecodablePoster", err)
+	}
+
+	// A new poster drops the thumbnails of the old one.
+	if _, err := dbh.WriteToDB(api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"}, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	if thumb, err := dbh.Thumbnail(348, 10); err != nil || thumb != nil {
+		t.Errorf("Thumbnail after removing the poster = %v bytes, %v, want nothing", len(thumb), err)
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// Batch groups writes in a single transaction, they're saved on Commit.
+// It has the write methods of DBHandler.
+type Batch struct {
+	tx *sql.Tx
+}
+
+// NewBatch starts a transaction.
+func (dbh *DBHandler) NewBatch() (*Batch, error) {
+	tx, err := dbh.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{tx: tx}, nil
+}
+
+func (b *Batch) WriteToDB(media api.Media, path string) (sql.Result, error) {
+	return writeMedia(b.tx, media, path)
+}
+
+func (b *Batch) QueueForReview(path string, title string, year int, candidate api.Media, reason string) error {
+	return queueForReview(b.tx, path, title, year, candidate, reason)
+}
+
+func (b *Batch) Unqueue(path string) error {
+	return unqueue(b.tx, path)
+}
+
+func (b *Batch) WriteSeason(showID int, season api.Season) error {
+	return writeSeason(b.tx, showID, season)
+}
+
+func (b *Batch) WriteEpisode(showID int, episode api.Episode, path string) error {
+	return writeEpisode(b.tx, showID, episode, path)
+}
+
+func (b *Batch) WriteCredits(mediaID int, credits []api.Credit) error {
+	return writeCredits(b.tx, mediaID, credits)
+}
+
+func (b *Batch) Commit() error {
+	return b.tx.Commit()
+}
+
+// Rollback discards the writes of b, after Commit it does nothing and returns sql.ErrTxDone.
+func (b *Batch) Rollback() error {
+	return b.tx.Rollback()
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ResponseCache stores api responses in the api_cache table, it implements api.Cache.
+// Its Set writes straight to the DB, concurrent lookups use a BufferedCache instead.
+type ResponseCache struct {
+	DBH *DBHandler
+	// MaxAge, if positive, is the age past which responses are deleted, see NewResponseCache.
+	MaxAge time.Duration
+	prune  *sync.Once
+}
+
+// NewResponseCache returns a cache in dbh deleting the responses older than maxAge when it stores its first one,
+// so once a run: api_cache has no index on fetched_at.
+func NewResponseCache(dbh *DBHandler, maxAge time.Duration) ResponseCache {
+	return ResponseCache{DBH: dbh, MaxAge: maxAge, prune: &sync.Once{}}
+}
+
+func (c ResponseCache) Get(key string, maxAge time.Duration) ([]byte, bool, error) {
+	var data []byte
+	var fetchedAt int64
+	err := c.DBH.DB.QueryRow("SELECT data, fetched_at FROM api_cache WHERE key=?", key).Scan(&data, &fetchedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if time.Since(time.Unix(fetchedAt, 0)) > maxAge {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+func (c ResponseCache) Set(key string, data []byte) error {
+	return c.set(c.DBH.DB, key, data, time.Now())
+}
+
+func (c ResponseCache) set(e execer, key string, data []byte, fetchedAt time.Time) error {
+	if c.prune != nil && c.MaxAge > 0 {
+		var err error
+		c.prune.Do(func() {
+			_, err = e.Exec("DELETE FROM api_cache WHERE fetched_at < ?", fetchedAt.Add(-c.MaxAge).Unix())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := e.Exec("INSERT OR REPLACE INTO api_cache(key, data, fetched_at) VALUES(?,?,?)", key, data, fetchedAt.Unix())
+	return err
+}
+
+// BufferedCache is a ResponseCache whose Set keeps responses in memory until Flush writes them in a batch,
+// so that the goroutines looking media up leave the writes to the one committing batches.
+type BufferedCache struct {
+	ResponseCache
+	mu      sync.Mutex
+	pending map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	data      []byte
+	fetchedAt time.Time
+}
+
+// Buffered returns a BufferedCache storing its responses in c.
+func (c ResponseCache) Buffered() *BufferedCache {
+	return &BufferedCache{ResponseCache: c, pending: make(map[string]cachedResponse)}
+}
+
+func (c *BufferedCache) Get(key string, maxAge time.Duration) ([]byte, bool, error) {
+	c.mu.Lock()
+	r, found := c.pending[key]
+	c.mu.Unlock()
+	if found && time.Since(r.fetchedAt) <= maxAge {
+		return r.data, true, nil
+	}
+	return c.ResponseCache.Get(key, maxAge)
+}
+
+func (c *BufferedCache) Set(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[key] = cachedResponse{data: data, fetchedAt: time.Now()}
+	return nil
+}
+
+// Flush writes the responses stored since the last Flush in b.
+// They're dropped even if b isn't committed, the cache only saves requests.
+func (c *BufferedCache) Flush(b *Batch) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]cachedResponse)
+	c.mu.Unlock()
+	for key, r := range pending {
+		if err := c.set(b.tx, key, r.data, r.fetchedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache := ResponseCache{DBH: openTestDB(t)}
+	key := "https://api.themoviedb.org/3/search/multi?query=Alien"
+	if _, found, err := cache.Get(key, time.Hour); err != nil || found {
+		t.Fatalf("empty cache: got found %v and error %v", found, err)
+	}
+	if err := cache.Set(key, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(key, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	data, found, err := cache.Get(key, time.Hour)
+	if err != nil || !found || string(data) != "second" {
+		t.Errorf("got %q, found %v and error %v, want the latest response", data, found, err)
+	}
+	if _, err := cache.DBH.DB.Exec("UPDATE api_cache SET fetched_at=fetched_at-7200"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := cache.Get(key, time.Hour); err != nil || found {
+		t.Errorf("expired response: got found %v and error %v", found, err)
+	}
+
+	// Storing the first response deletes the expired ones, the next ones don't.
+	cache = NewResponseCache(cache.DBH, time.Hour)
+	for i, want := range []int{1, 2} {
+		if _, err := cache.DBH.DB.Exec("UPDATE api_cache SET fetched_at=fetched_at-7200"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Set(key+fmt.Sprint(i), []byte("new")); err != nil {
+			t.Fatal(err)
+		}
+		var rows int
+		if err := cache.DBH.DB.QueryRow("SELECT count(*) FROM api_cache").Scan(&rows); err != nil || rows != want {
+			t.Errorf("Set #%v: got %v rows, %v, want %v", i, rows, err, want)
+		}
+	}
+}
+
+func TestBufferedCache(t *testing.T) {
+	dbh := openTestDB(t)
+	cache := NewResponseCache(dbh, time.Hour).Buffered()
+	key := "https://api.themoviedb.org/3/search/multi?query=Alien"
+	if err := cache.Set(key, []byte("response")); err != nil {
+		t.Fatal(err)
+	}
+	rows := func() int {
+		var rows int
+		if err := dbh.DB.QueryRow("SELECT count(*) FROM api_cache").Scan(&rows); err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	if n := rows(); n != 0 {
+		t.Errorf("Set wrote %v rows before Flush", n)
+	}
+	if data, found, err := cache.Get(key, time.Hour); err != nil || !found || string(data) != "response" {
+		t.Errorf("before Flush: got %q, found %v and error %v", data, found, err)
+	}
+
+	b, err := dbh.NewBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Flush(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rows(); n != 1 {
+		t.Errorf("got %v rows after Flush, want 1", n)
+	}
+	if data, found, err := cache.ResponseCache.Get(key, time.Hour); err != nil || !found || string(data) != "response" {
+		t.Errorf("after Flush: got %q, found %v and error %v", data, found, err)
+	}
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// WriteCredits replaces the credits of the media with id mediaID, and writes their people.
+func (dbh *DBHandler) WriteCredits(mediaID int, credits []api.Credit) error {
+	return writeCredits(dbh.DB, mediaID, credits)
+}
+
+func writeCredits(e execer, mediaID int, credits []api.Credit) error {
+	if _, err := e.Exec("DELETE FROM credits WHERE media_id=?", mediaID); err != nil {
+		return err
+	}
+	for _, c := range credits {
+		if _, err := e.Exec("INSERT INTO people(id, name) VALUES(?,?) ON CONFLICT(id) DO UPDATE SET name=excluded.name", c.PersonID, c.Name); err != nil {
+			return err
+		}
+		dbInsert := "INSERT OR REPLACE INTO credits(media_id, person_id, role, job, character, billing_order) VALUES(?,?,?,?,?,?)"
+		character := sql.NullString{String: c.Character, Valid: c.Role == api.RoleCast}
+		order := sql.NullInt64{Int64: int64(c.Order), Valid: c.Role == api.RoleCast}
+		if _, err := e.Exec(dbInsert, mediaID, c.PersonID, c.Role, c.Job, character, order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PersonCredit is a credit of a person on a media of the db.
+type PersonCredit struct {
+	api.Credit
+	// Media has no poster.
+	Media Record
+}
+
+// PersonCredits lists the credits of the people whose name contains name, case-insensitively, on the media of the db.
+// They're ordered by person, then by media year and title, cast first.
+func (dbh *DBHandler) PersonCredits(name string) ([]PersonCredit, error) {
+	query := `SELECT people.id, people.name, credits.role, credits.job, credits.character, credits.billing_order,
+	media.id, media.media_type, media.title, media.year, media.path
+FROM credits JOIN people ON people.id=credits.person_id JOIN media ON media.id=credits.media_id
+WHERE people.name LIKE ? ESCAPE '\'
+ORDER BY people.name, people.id, media.year, media.title, credits.role='cast' DESC, credits.role, credits.job`
+	rows, err := dbh.DB.Query(query, likeAny(name))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var credits []PersonCredit
+	for rows.Next() {
+		var c PersonCredit
+		var character, path sql.NullString
+		var order sql.NullInt64
+		var title string
+		var year int
+		if err := rows.Scan(&c.PersonID, &c.Name, &c.Role, &c.Job, &character, &order,
+			&c.Media.ID, &c.Media.MediaType, &title, &year, &path); err != nil {
+			return nil, err
+		}
+		c.Character, c.Order, c.Media.Path = character.String, int(order.Int64), path.String
+		c.Media.SetTitleAndYear(title, year)
+		credits = append(credits, c)
+	}
+	return credits, rows.Err()
+}
+
+// escapeLike escapes the wildcards of s for a LIKE pattern with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Credits lists the credits of the media with id mediaID, cast first in billing order, then crew by role.
+func (dbh *DBHandler) Credits(mediaID int) ([]api.Credit, error) {
+	query := `SELECT people.id, people.name, credits.role, credits.job, credits.character, credits.billing_order
+FROM credits JOIN people ON people.id=credits.person_id
+WHERE credits.media_id=?
+ORDER BY credits.role='cast' DESC, credits.billing_order, credits.role, credits.job, people.name`
+	rows, err := dbh.DB.Query(query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var credits []api.Credit
+	for rows.Next() {
+		var c api.Credit
+		var character sql.NullString
+		var order sql.NullInt64
+		if err := rows.Scan(&c.PersonID, &c.Name, &c.Role, &c.Job, &character, &order); err != nil {
+			return nil, err
+		}
+		c.Character, c.Order = character.String, int(order.Int64)
+		credits = append(credits, c)
+	}
+	return credits, rows.Err()
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestPersonCredits(t *testing.T) {
+	dbh := openTestDB(t)
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"}
+	heat := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"}
+	for _, m := range []api.Media{alien, heat} {
+		if _, err := dbh.WriteToDB(m, "/films/"+m.Title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writes := []struct {
+		mediaID int
+		credits []api.Credit
+	}{
+		{348, []api.Credit{
+			{PersonID: 10205, Name: "Sigourney Weaver", Role: api.RoleCast, Character: "Ellen Ripley"},
+			{PersonID: 578, Name: "Ridley Scott", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 638, Name: "Michael Mann", Role: api.RoleWriter, Job: "Screenplay"},
+		}},
+		{949, []api.Credit{
+			{PersonID: 638, Name: "Michael Mann", Role: api.RoleWriter, Job: "Writer"},
+			{PersonID: 638, Name: "Michael Mann", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 1158, Name: "Al Pacino", Role: api.RoleCast, Character: "Lt. Vincent Hanna"},
+		}},
+		// Writing again replaces: Michael Mann didn't write Alien.
+		{348, []api.Credit{
+			{PersonID: 10205, Name: "Sigourney Weaver", Role: api.RoleCast, Character: "Ellen Ripley"},
+			{PersonID: 578, Name: "Ridley Scott", Role: api.RoleDirector, Job: "Director"},
+		}},
+		// Credits of a media that isn't in the db.
+		{1, []api.Credit{{PersonID: 638, Name: "Michael Mann", Role: api.RoleDirector, Job: "Director"}}},
+	}
+	for _, w := range writes {
+		if err := dbh.WriteCredits(w.mediaID, w.credits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"michael mann", []string{"Michael Mann: Heat Director", "Michael Mann: Heat Writer"}},
+		{"Sigourney", []string{"Sigourney Weaver: Alien as Ellen Ripley"}},
+		{"i", []string{
+			"Al Pacino: Heat as Lt. Vincent Hanna",
+			"Michael Mann: Heat Director", "Michael Mann: Heat Writer",
+			"Ridley Scott: Alien Director",
+			"Sigourney Weaver: Alien as Ellen Ripley",
+		}},
+		{"%", nil},
+		{"Nobody", nil},
+	}
+	for _, test := range tests {
+		credits, err := dbh.PersonCredits(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range credits {
+			got = append(got, fmt.Sprintf("%v: %v %v", c.Name, c.Media.GetTitle(), c.Credit))
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("PersonCredits(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCredits(t *testing.T) {
+	dbh := openTestDB(t)
+	credits := []api.Credit{
+		{PersonID: 638, Name: "Michael Mann", Role: api.RoleWriter, Job: "Writer"},
+		{PersonID: 638, Name: "Michael Mann", Role: api.RoleDirector, Job: "Director"},
+		{PersonID: 380, Name: "Robert De Niro", Role: api.RoleCast, Character: "Neil McCauley", Order: 1},
+		{PersonID: 1158, Name: "Al Pacino", Role: api.RoleCast, Character: "Lt. Vincent Hanna"},
+	}
+	if err := dbh.WriteCredits(949, credits); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dbh.Credits(949)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []api.Credit{credits[3], credits[2], credits[1], credits[0]}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
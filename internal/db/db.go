@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strconv"
+	"net/url"
+	"os"
+	"path/filepath"
 
 	"github.com/JeanLeonHenry/mymedia/internal/api"
 	_ "modernc.org/sqlite"
@@ -16,55 +18,268 @@ type DBHandler struct {
 	DB   *sql.DB
 }
 
+// NewDB opens the db at path, see Open. Exits if that fails.
 func NewDB(path string) *DBHandler {
-	// FIX: dbh should ensure the existence of db instead of panicing?
-	db, err := sql.Open("sqlite", path)
+	dbh, err := Open(path)
 	if err != nil {
 		log.Fatal("Error opening db file at '", path, "' ", err)
 	}
+	return dbh
+}
+
+// Open opens the db at path, creating it and its parent dir if needed,
+// and upgrades its schema to the latest version.
+func Open(path string) (*DBHandler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// Wait for concurrent writers instead of failing with SQLITE_BUSY.
+	dsn := url.URL{
+		Scheme: "file",
+		// Escaped, so that a ?, # or % in path doesn't end it.
+		Opaque:   (&url.URL{Path: path}).EscapedPath(),
+		RawQuery: "_pragma=busy_timeout(5000)",
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, err
+	}
 	if err := db.Ping(); err != nil {
-		log.Fatal("Error pinging '", path, "' file", err)
+		db.Close()
+		return nil, err
+	}
+	dbh := &DBHandler{Path: path, DB: db}
+	if err := dbh.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return dbh, nil
+}
+
+// Record is a row of the media table.
+type Record struct {
+	api.Media
+	Path string
+	// HasPoster is set by ListMedia, which doesn't read PosterData.
+	HasPoster bool
+}
+
+// recordColumns are the columns of the media table read by scanRecord.
+const recordColumns = `media.title, media.year, media.id, media.media_type, media.overview, media.director, media.path,
+	media.release_date, media.runtime, media.vote_average, media.tagline, media.original_title, media.original_language, media.imdb_id,
+	media.original_overview`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRecord reads recordColumns, followed by the columns read into extra, into a record.
+// Its genres aren't read.
+func scanRecord(row rowScanner, extra ...any) (Record, error) {
+	var record Record
+	var titleDB string
+	var yearDB int
+	var overview, director, path, releaseDate, tagline, originalTitle, originalLanguage, imdbID, originalOverview sql.NullString
+	var runtime sql.NullInt64
+	var voteAverage sql.NullFloat64
+	dest := []any{&titleDB, &yearDB, &record.ID, &record.MediaType, &overview, &director, &path,
+		&releaseDate, &runtime, &voteAverage, &tagline, &originalTitle, &originalLanguage, &imdbID,
+		&originalOverview}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return record, err
+	}
+	record.Overview, record.Director, record.Path = overview.String, director.String, path.String
+	record.Runtime, record.VoteAverage, record.Tagline = int(runtime.Int64), voteAverage.Float64, tagline.String
+	record.OriginalLanguage, record.IMDbID, record.OriginalOverview = originalLanguage.String, imdbID.String, originalOverview.String
+	record.SetOriginalTitle(originalTitle.String)
+	if releaseDate.String != "" {
+		record.SetTitleAndDate(titleDB, releaseDate.String)
+	} else {
+		// Written before release dates were stored.
+		record.SetTitleAndYear(titleDB, yearDB)
+	}
+	return record, nil
+}
+
+// FindMedia looks up the db for a media record with a case-insensitive matching title, or original title, and a year within tolerance of year.
+// A matching title comes first. Falls back on FindMediaFuzzy if no title matches.
+func (dbh *DBHandler) FindMedia(title string, year int, tolerance int) (record Record, found bool, err error) {
+	dBQuery := "SELECT " + recordColumns + `, media.poster FROM media
+WHERE (lower(media.title)=lower(?) OR lower(media.original_title)=lower(?)) AND ABS(media.year-?)<=?
+ORDER BY lower(media.title)=lower(?) DESC`
+	var poster []byte
+	record, err = scanRecord(dbh.DB.QueryRow(dBQuery, title, title, year, tolerance, title), &poster)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbh.FindMediaFuzzy(title, year, tolerance)
+		}
+		return record, false, err
+	}
+	record.PosterData, record.HasPoster = poster, len(poster) > 0
+	if record.Genres, err = dbh.genres(record.ID); err != nil {
+		return record, false, err
+	}
+	return record, true, nil
+}
+
+// FindMediaByID looks up the db for the media record with id.
+func (dbh *DBHandler) FindMediaByID(id int) (record Record, found bool, err error) {
+	var poster []byte
+	record, err = scanRecord(dbh.DB.QueryRow("SELECT "+recordColumns+", media.poster FROM media WHERE media.id=?", id), &poster)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, false, nil
+	}
+	if err != nil {
+		return record, false, err
+	}
+	record.PosterData, record.HasPoster = poster, len(poster) > 0
+	if record.Genres, err = dbh.genres(record.ID); err != nil {
+		return record, false, err
+	}
+	return record, true, nil
+}
+
+// Poster returns the poster of the media with id, empty if it has none.
+func (dbh *DBHandler) Poster(id int) ([]byte, error) {
+	var poster []byte
+	err := dbh.DB.QueryRow("SELECT poster FROM media WHERE id=?", id).Scan(&poster)
+	return poster, err
+}
+
+// genres lists the genres of the media with id mediaID, by name.
+func (dbh *DBHandler) genres(mediaID int) ([]api.Genre, error) {
+	query := "SELECT genres.id, genres.name FROM media_genres JOIN genres ON genres.id=media_genres.genre_id WHERE media_genres.media_id=? ORDER BY genres.name"
+	rows, err := dbh.DB.Query(query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var genres []api.Genre
+	for rows.Next() {
+		var g api.Genre
+		if err := rows.Scan(&g.ID, &g.Name); err != nil {
+			return nil, err
+		}
+		genres = append(genres, g)
 	}
-	return &DBHandler{Path: path, DB: db}
+	return genres, rows.Err()
 }
 
 // checkDB looks up the db for a media record with case-insensitive matching titles and a year within tolerance of year
 func (dbh *DBHandler) CheckDB(title string, year int, tolerance int, debug bool) bool {
-	dBQuery := "SELECT title, year, id, media_type, overview, director FROM media WHERE lower(media.title)=lower(?) AND ABS(media.year-?)<=?"
-	rows := dbh.DB.QueryRow(dBQuery, title, year, tolerance)
-	var titleDB, media_type, overview, director string
-	var yearDB, id int
-	if err := rows.Scan(&titleDB, &yearDB, &id, &media_type, &overview, &director); err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			log.Fatal(" Query error: ", err)
-		}
+	record, found, err := dbh.FindMedia(title, year, tolerance)
+	if err != nil {
+		log.Fatal(" Query error: ", err)
+	}
+	if !found {
 		// found no match, check is complete
 		return false
 	}
-	result := api.Media{
-		ID:        id,
-		MediaType: media_type,
-		Director:  director,
-		Overview:  overview,
-	}
-	// HACK: why not store and use the whole date
-	dateFromYear := strconv.Itoa(yearDB) + "-01-01"
-	if media_type == api.MediaTypeTV {
-		result.FirstAirDate = dateFromYear
-		result.Name = titleDB
-	} else {
-		result.ReleaseDate = dateFromYear
-		result.Title = titleDB
-	}
-	out := result.String()
+	out := record.String()
 	if debug {
-		out = result.Dump()
+		out = record.Dump()
 	}
 	fmt.Printf("✓ Found %v in DB.\n", out)
 	return true
 }
+
+// execer runs statements, it's implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 func (dbh *DBHandler) WriteToDB(media api.Media, path string) (sql.Result, error) {
-	dbInsert := "INSERT OR REPLACE INTO media(id, media_type, title, year, overview, director, poster, path) VALUES(?,?,?,?,?,?,?,?)"
-	res, err := dbh.DB.Exec(dbInsert, media.ID, media.MediaType, media.GetTitle(), media.GetYear(), media.Overview, media.Director, media.PosterData, path)
-	return res, err
+	return writeMedia(dbh.DB, media, path)
+}
+
+// writeMedia writes media and replaces its genres, and its artwork of the kinds in media.Artwork.
+// The thumbnails of its poster are deleted, they're made again when needed, see Thumbnail.
+func writeMedia(e execer, media api.Media, path string) (sql.Result, error) {
+	dbInsert := `INSERT OR REPLACE INTO media(id, media_type, title, year, overview, director, poster, path,
+	release_date, runtime, vote_average, tagline, original_title, original_language, imdb_id, original_overview) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	res, err := e.Exec(dbInsert, media.ID, media.MediaType, media.GetTitle(), media.GetYear(), media.Overview, media.Director, media.PosterData, path,
+		media.GetReleaseDate(), media.GetRuntime(), media.VoteAverage, media.Tagline, media.GetOriginalTitle(), media.OriginalLanguage, media.IMDbID, media.OriginalOverview)
+	if err != nil {
+		return res, err
+	}
+	if _, err := e.Exec("DELETE FROM media_genres WHERE media_id=?", media.ID); err != nil {
+		return res, err
+	}
+	for _, g := range media.Genres {
+		if _, err := e.Exec("INSERT INTO genres(id, name) VALUES(?,?) ON CONFLICT(id) DO UPDATE SET name=excluded.name", g.ID, g.Name); err != nil {
+			return res, err
+		}
+		if _, err := e.Exec("INSERT OR IGNORE INTO media_genres(media_id, genre_id) VALUES(?,?)", media.ID, g.ID); err != nil {
+			return res, err
+		}
+	}
+	if _, err := e.Exec("DELETE FROM artwork WHERE media_id=? AND kind=?", media.ID, api.ArtworkThumbnail); err != nil {
+		return res, err
+	}
+	for _, a := range media.Artwork {
+		if _, err := e.Exec("DELETE FROM artwork WHERE media_id=? AND kind=?", media.ID, a.Kind); err != nil {
+			return res, err
+		}
+	}
+	for _, a := range media.Artwork {
+		if err := writeArtwork(e, media.ID, a); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// ReviewItem is a media folder whose match needs a human decision.
+type ReviewItem struct {
+	Path      string
+	Title     string
+	Year      int
+	Candidate api.Media
+	Reason    string
+	QueuedAt  string
+}
+
+// QueueForReview records that the folder at path, read as title and year, was matched to candidate without confidence.
+// A folder is queued at most once, queuing it again replaces the previous item.
+func (dbh *DBHandler) QueueForReview(path string, title string, year int, candidate api.Media, reason string) error {
+	return queueForReview(dbh.DB, path, title, year, candidate, reason)
+}
+
+func queueForReview(e execer, path string, title string, year int, candidate api.Media, reason string) error {
+	dbInsert := "INSERT OR REPLACE INTO review_queue(path, title, year, candidate_id, candidate_media_type, candidate_title, candidate_year, reason) VALUES(?,?,?,?,?,?,?,?)"
+	_, err := e.Exec(dbInsert, path, title, year, candidate.ID, candidate.MediaType, candidate.GetTitle(), candidate.GetYear(), reason)
+	return err
+}
+
+// Unqueue removes the folder at path from the review queue, if it's there.
+func (dbh *DBHandler) Unqueue(path string) error {
+	return unqueue(dbh.DB, path)
+}
+
+func unqueue(e execer, path string) error {
+	_, err := e.Exec("DELETE FROM review_queue WHERE path=?", path)
+	return err
+}
+
+// ReviewQueue lists the review queue, oldest first.
+func (dbh *DBHandler) ReviewQueue() ([]ReviewItem, error) {
+	query := "SELECT path, title, year, candidate_id, candidate_media_type, candidate_title, candidate_year, reason, queued_at FROM review_queue ORDER BY queued_at, path"
+	rows, err := dbh.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReviewItem
+	for rows.Next() {
+		var item ReviewItem
+		var candidateTitle string
+		var candidateYear int
+		if err := rows.Scan(&item.Path, &item.Title, &item.Year, &item.Candidate.ID, &item.Candidate.MediaType, &candidateTitle, &candidateYear, &item.Reason, &item.QueuedAt); err != nil {
+			return nil, err
+		}
+		item.Candidate.SetTitleAndYear(candidateTitle, candidateYear)
+		items = append(items, item)
+	}
+	return items, rows.Err()
 }
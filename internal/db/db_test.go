@@ -0,0 +1,223 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// openTestDB opens a new db in a temp dir, closed when t ends.
+func openTestDB(t *testing.T) *DBHandler {
+	t.Helper()
+	dbh, err := Open(filepath.Join(t.TempDir(), "sub", "media.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbh.DB.Close() })
+	return dbh
+}
+
+func TestOpenCreatesSchema(t *testing.T) {
+	dbh := openTestDB(t)
+	version, err := dbh.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("schema version is %v, want %v", version, len(migrations))
+	}
+	for _, table := range []string{"media", "review_queue", "api_cache", "seasons", "episodes", "people", "credits", "genres", "media_genres", "media_fts"} {
+		var name string
+		if err := dbh.DB.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&name); err != nil {
+			t.Errorf("table %v: %v", table, err)
+		}
+	}
+}
+
+func TestOpenMigratesHandMadeDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "media.db")
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The table the README used to tell users to create.
+	if _, err := legacy.Exec(`CREATE TABLE "media" ("id" UNIQUE, "media_type", "title", "year", "overview", "director", "poster", "path")`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`INSERT INTO media VALUES (348, 'movie', 'Alien', 1979, '', 'Ridley Scott', NULL, '/films/Alien (1979)')`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	dbh, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.DB.Close()
+	if version, _ := dbh.SchemaVersion(); version != len(migrations) {
+		t.Errorf("schema version is %v, want %v", version, len(migrations))
+	}
+	if !dbh.CheckDB("alien", 1980, 1, false) {
+		t.Error("lost the existing row")
+	}
+	// Opening again is a no-op.
+	dbh.DB.Close()
+	if dbh, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	dbh.DB.Close()
+}
+
+func TestOpenRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "media.db")
+	dbh, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbh.DB.Exec("PRAGMA user_version = 1000"); err != nil {
+		t.Fatal(err)
+	}
+	dbh.DB.Close()
+	if _, err := Open(path); err == nil {
+		t.Error("opened a db with a newer schema")
+	}
+}
+
+func TestOpenEscapesPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "100% media?mode=ro#1.db")
+	dbh, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.DB.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("db not created at its path: %v", err)
+	}
+	var timeout int
+	if err := dbh.DB.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("got busy timeout %v, %v, want 5000", timeout, err)
+	}
+}
+
+func TestWriteAndCheckDB(t *testing.T) {
+	dbh := openTestDB(t)
+	media := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15", Director: "Michael Mann"}
+	if dbh.CheckDB("Heat", 1995, 0, false) {
+		t.Fatal("found Heat in an empty db")
+	}
+	if _, err := dbh.WriteToDB(media, "/films/Heat (1995)"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		title     string
+		year      int
+		tolerance int
+		want      bool
+	}{
+		{"Heat", 1995, 0, true},
+		{"HEAT", 1997, 2, true},
+		{"Heat", 1997, 1, false},
+		{"Hea", 1995, 0, false},
+	}
+	for _, test := range tests {
+		if got := dbh.CheckDB(test.title, test.year, test.tolerance, false); got != test.want {
+			t.Errorf("CheckDB(%q, %v, %v) = %v, want %v", test.title, test.year, test.tolerance, got, test.want)
+		}
+	}
+}
+
+func TestFindMediaDetails(t *testing.T) {
+	dbh := openTestDB(t)
+	heat := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15",
+		Genres:  []api.Genre{{ID: 80, Name: "Crime"}, {ID: 18, Name: "Drama"}},
+		Runtime: 170, VoteAverage: 7.9, Tagline: "A Los Angeles crime saga", OriginalTitle: "Heat", OriginalLanguage: "en", IMDbID: "tt0113277"}
+	show := api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14",
+		Genres: []api.Genre{{ID: 18, Name: "Drama"}}, EpisodeRunTime: []int{43}, OriginalName: "The Expanse"}
+	for _, m := range []api.Media{heat, show} {
+		if _, err := dbh.WriteToDB(m, "/media/"+m.GetTitle()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Writing again replaces the genres.
+	heat.Genres = []api.Genre{{ID: 80, Name: "Crime"}, {ID: 28, Name: "Action"}}
+	if _, err := dbh.WriteToDB(heat, "/media/Heat"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		want   api.Media
+		genres []string
+	}{
+		{heat, []string{"Action", "Crime"}},
+		{show, []string{"Drama"}},
+	}
+	for _, test := range tests {
+		record, found, err := dbh.FindMedia(test.want.GetTitle(), test.want.GetYear(), 0)
+		if err != nil || !found {
+			t.Fatalf("FindMedia(%q): found %v, %v", test.want.GetTitle(), found, err)
+		}
+		got := record.Media
+		if got.GetReleaseDate() != test.want.GetReleaseDate() || got.GetRuntime() != test.want.GetRuntime() || got.VoteAverage != test.want.VoteAverage ||
+			got.Tagline != test.want.Tagline || got.GetOriginalTitle() != test.want.GetOriginalTitle() || got.OriginalLanguage != test.want.OriginalLanguage || got.IMDbID != test.want.IMDbID {
+			t.Errorf("FindMedia(%q) = %+v, want %+v", test.want.GetTitle(), got, test.want)
+		}
+		if !slices.Equal(got.GenreNames(), test.genres) {
+			t.Errorf("FindMedia(%q) has genres %q, want %q", test.want.GetTitle(), got.GenreNames(), test.genres)
+		}
+	}
+}
+
+func TestFindMediaOriginalTitle(t *testing.T) {
+	dbh := openTestDB(t)
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien, le huitième passager", ReleaseDate: "1979-05-25",
+		Overview: "Durant le voyage de retour", OriginalTitle: "Alien", OriginalOverview: "During its return to the earth"}
+	// Titled like Alien's original title.
+	other := api.Media{ID: 1, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-01-01", OriginalTitle: "Alien"}
+	if _, err := dbh.WriteToDB(alien, "/media/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	record, found, err := dbh.FindMedia("alien", 1979, 0)
+	if err != nil || !found || record.ID != alien.ID || record.Overview != alien.Overview || record.OriginalOverview != alien.OriginalOverview {
+		t.Errorf("FindMedia(alien) = %+v, %v, %v, want Alien with both overviews", record.Media, found, err)
+	}
+	if _, err := dbh.WriteToDB(other, "/media/Other"); err != nil {
+		t.Fatal(err)
+	}
+	if record, found, err := dbh.FindMedia("alien", 1979, 0); err != nil || !found || record.ID != other.ID {
+		t.Errorf("FindMedia(alien) = %+v, %v, %v, want the media titled Alien first", record.Media, found, err)
+	}
+}
+
+func TestReviewQueue(t *testing.T) {
+	dbh := openTestDB(t)
+	heat := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"}
+	if err := dbh.QueueForReview("/films/Heat (1990)", "Heat", 1990, heat, "several matches"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.QueueForReview("/films/Heat (1990)", "Heat", 1990, heat, "closest match year 1995 is not within 2 of 1990"); err != nil {
+		t.Fatal(err)
+	}
+	items, err := dbh.ReviewQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %v items, want a single one", len(items))
+	}
+	item := items[0]
+	if item.Candidate.ID != 949 || item.Candidate.GetTitle() != "Heat" || item.Candidate.GetYear() != 1995 {
+		t.Errorf("candidate is %v, want Heat (1995)", item.Candidate)
+	}
+	if item.Reason != "closest match year 1995 is not within 2 of 1990" {
+		t.Errorf("reason is %q, want the latest one", item.Reason)
+	}
+	if err := dbh.Unqueue(item.Path); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := dbh.ReviewQueue(); len(items) != 0 {
+		t.Errorf("got %v items after unqueuing, want none", len(items))
+	}
+}
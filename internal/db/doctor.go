@@ -0,0 +1,84 @@
+package db
+
+// PosterProblem is a media record whose poster failed a check, see CheckPosters.
+type PosterProblem struct {
+	Record
+	Err error
+}
+
+// CheckPosters runs check on the poster of every media, an empty slice for a missing poster, and returns the records it failed on.
+// Posters are read one at a time, the records have neither poster nor genres.
+func (dbh *DBHandler) CheckPosters(check func(poster []byte) error) ([]PosterProblem, error) {
+	rows, err := dbh.DB.Query("SELECT " + recordColumns + ", media.poster FROM media ORDER BY media.path, media.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []PosterProblem
+	for rows.Next() {
+		var poster []byte
+		record, err := scanRecord(rows, &poster)
+		if err != nil {
+			return nil, err
+		}
+		record.HasPoster = len(poster) > 0
+		if err := check(poster); err != nil {
+			problems = append(problems, PosterProblem{Record: record, Err: err})
+		}
+	}
+	return problems, rows.Err()
+}
+
+// DuplicatePaths lists the media records sharing their path with another, by path.
+// Their posters aren't read, see Record.HasPoster.
+func (dbh *DBHandler) DuplicatePaths() ([]Record, error) {
+	query := "SELECT " + recordColumns + `, media.poster IS NOT NULL AND length(media.poster)>0 FROM media
+WHERE media.path IN (SELECT path FROM media GROUP BY path HAVING count(*)>1) ORDER BY media.path, media.id`
+	return dbh.queryRecords(query)
+}
+
+// DuplicateIDs lists the media records sharing their id with another, by id.
+// The id column is unique in the schema, but tables made by hand before versioning may lack the constraint.
+// Their posters aren't read, see Record.HasPoster.
+func (dbh *DBHandler) DuplicateIDs() ([]Record, error) {
+	query := "SELECT " + recordColumns + `, media.poster IS NOT NULL AND length(media.poster)>0 FROM media
+WHERE media.id IN (SELECT id FROM media GROUP BY id HAVING count(*)>1) ORDER BY media.id, media.path`
+	return dbh.queryRecords(query)
+}
+
+// DeleteMedia deletes the media record with id at path.
+// Unless another record has id, its credits, genres, seasons and episodes are deleted too.
+func (dbh *DBHandler) DeleteMedia(id int, path string) error {
+	tx, err := dbh.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM media WHERE id=? AND path=?", id, path); err != nil {
+		return err
+	}
+	var others int
+	if err := tx.QueryRow("SELECT count(*) FROM media WHERE id=?", id).Scan(&others); err != nil {
+		return err
+	}
+	if others > 0 {
+		// The delete trigger removed the index entry of id, that the other record needs.
+		if _, err := tx.Exec(`INSERT INTO media_fts(rowid, title, original_title, overview, director)
+	SELECT id, title, original_title, overview, director FROM media WHERE id=? LIMIT 1`, id); err != nil {
+			return err
+		}
+	} else {
+		for _, statement := range []string{
+			"DELETE FROM credits WHERE media_id=?",
+			"DELETE FROM media_genres WHERE media_id=?",
+			"DELETE FROM seasons WHERE show_id=?",
+			"DELETE FROM episodes WHERE show_id=?",
+			"DELETE FROM artwork WHERE media_id=?",
+		} {
+			if _, err := tx.Exec(statement, id); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
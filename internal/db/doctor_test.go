@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestCheckPosters(t *testing.T) {
+	dbh := openTestDB(t)
+	for _, m := range []api.Media{
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", PosterData: []byte("poster")},
+		{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15", PosterData: []byte("bad")},
+		{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14"},
+	} {
+		if _, err := dbh.WriteToDB(m, "/media/"+m.GetTitle()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errBad := errors.New("bad poster")
+	problems, err := dbh.CheckPosters(func(poster []byte) error {
+		if string(poster) != "poster" {
+			return errBad
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, p := range problems {
+		if p.Err != errBad {
+			t.Errorf("%v: got error %v, want %v", p.GetTitle(), p.Err, errBad)
+		}
+		ids = append(ids, p.ID)
+	}
+	if want := []int{949, 63639}; !slices.Equal(ids, want) {
+		t.Errorf("got problems with %v, want %v", ids, want)
+	}
+}
+
+func TestDuplicatePaths(t *testing.T) {
+	dbh := openTestDB(t)
+	for _, m := range []api.Media{
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"},
+		{ID: 679, MediaType: api.MediaTypeMovie, Title: "Aliens", ReleaseDate: "1986-07-18"},
+	} {
+		if _, err := dbh.WriteToDB(m, "/films/Alien (1979)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dbh.WriteToDB(api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"}, "/films/Heat (1995)"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := dbh.DuplicatePaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != 348 || records[1].ID != 679 {
+		t.Errorf("got %+v, want Alien and Aliens", records)
+	}
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "media.db")
+	legacy, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A table made by hand without the unique id.
+	if _, err := legacy.Exec(`CREATE TABLE media (id, media_type, title, year, overview, director, poster, path)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`INSERT INTO media VALUES (348, 'movie', 'Alien', 1979, '', '', NULL, '/films/Alien (1979)'),
+	(348, 'movie', 'Alien', 1979, '', '', NULL, '/old/Alien'), (949, 'movie', 'Heat', 1995, '', '', NULL, '/films/Heat (1995)')`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+	dbh, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.DB.Close()
+
+	records, err := dbh.DuplicateIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Path != "/films/Alien (1979)" || records[1].Path != "/old/Alien" {
+		t.Errorf("got %+v, want both Aliens", records)
+	}
+	if err := dbh.WriteCredits(348, []api.Credit{{PersonID: 578, Name: "Ridley Scott", Role: api.RoleDirector, Job: "Director"}}); err != nil {
+		t.Fatal(err)
+	}
+	// The other Alien keeps its credits.
+	if err := dbh.DeleteMedia(348, "/old/Alien"); err != nil {
+		t.Fatal(err)
+	}
+	if credits, err := dbh.PersonCredits("Ridley Scott"); err != nil || len(credits) != 1 {
+		t.Errorf("got credits %+v (error %v), want Alien's", credits, err)
+	}
+	if records, err := dbh.SearchMedia("alien", 0); err != nil || len(records) != 1 || records[0].Path != "/films/Alien (1979)" {
+		t.Errorf("searched %+v (error %v), want the other Alien", records, err)
+	}
+	if records, err := dbh.DuplicateIDs(); err != nil || len(records) != 0 {
+		t.Errorf("got duplicates %+v (error %v), want none", records, err)
+	}
+}
+
+func TestDeleteMedia(t *testing.T) {
+	dbh := openTestDB(t)
+	show := api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14",
+		Genres: []api.Genre{{ID: 18, Name: "Drama"}}}
+	if _, err := dbh.WriteToDB(show, "/shows/The Expanse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.WriteCredits(show.ID, []api.Credit{{PersonID: 1, Name: "Steven Strait", Role: api.RoleCast}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.WriteSeason(show.ID, api.Season{SeasonNumber: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.WriteEpisode(show.ID, api.Episode{SeasonNumber: 1, EpisodeNumber: 1}, "/shows/The Expanse/S01E01.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.DeleteMedia(show.ID, "/shows/The Expanse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"media", "credits", "media_genres", "seasons", "episodes", "media_fts"} {
+		var n int
+		if err := dbh.DB.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%v rows left in %v", n, table)
+		}
+	}
+}
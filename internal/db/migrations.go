@@ -0,0 +1,191 @@
+package db
+
+import "fmt"
+
+// migration upgrades the schema by one version.
+type migration struct {
+	description string
+	statements  []string
+}
+
+// migrations are applied in order, migrations[i] upgrades the schema from version i to i+1.
+// The schema version is stored in PRAGMA user_version.
+// Never edit a released migration: append a new one.
+var migrations = []migration{
+	{
+		description: "create media table",
+		// IF NOT EXISTS: databases created by hand before versioning have the table already.
+		statements: []string{`CREATE TABLE IF NOT EXISTS media (
+	id INTEGER UNIQUE,
+	media_type TEXT,
+	title TEXT,
+	year INTEGER,
+	overview TEXT,
+	director TEXT,
+	poster BLOB,
+	path TEXT
+)`},
+	},
+	{
+		description: "create review queue",
+		// IF NOT EXISTS: NewDB used to create it unversioned.
+		statements: []string{`CREATE TABLE IF NOT EXISTS review_queue (
+	path TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	year INTEGER NOT NULL,
+	candidate_id INTEGER,
+	candidate_media_type TEXT,
+	candidate_title TEXT,
+	candidate_year INTEGER,
+	reason TEXT NOT NULL,
+	queued_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`},
+	},
+	{
+		description: "create api response cache",
+		statements: []string{`CREATE TABLE api_cache (
+	key TEXT PRIMARY KEY,
+	data BLOB NOT NULL,
+	fetched_at INTEGER NOT NULL
+)`},
+	},
+	{
+		description: "create TV seasons and episodes",
+		statements: []string{`CREATE TABLE seasons (
+	show_id INTEGER NOT NULL,
+	season_number INTEGER NOT NULL,
+	id INTEGER,
+	name TEXT,
+	overview TEXT,
+	air_date TEXT,
+	PRIMARY KEY (show_id, season_number)
+)`, `CREATE TABLE episodes (
+	show_id INTEGER NOT NULL,
+	season_number INTEGER NOT NULL,
+	episode_number INTEGER NOT NULL,
+	id INTEGER,
+	name TEXT,
+	overview TEXT,
+	air_date TEXT,
+	still BLOB,
+	path TEXT,
+	PRIMARY KEY (show_id, season_number, episode_number)
+)`},
+	},
+	{
+		description: "create people and credits",
+		statements: []string{`CREATE TABLE people (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+)`, `CREATE TABLE credits (
+	media_id INTEGER NOT NULL,
+	person_id INTEGER NOT NULL REFERENCES people(id),
+	role TEXT NOT NULL,
+	job TEXT NOT NULL DEFAULT '',
+	character TEXT,
+	billing_order INTEGER,
+	PRIMARY KEY (media_id, person_id, role, job)
+)`, `CREATE INDEX credits_person_id ON credits(person_id)`},
+	},
+	{
+		description: "add media details and genres",
+		// Rows written before keep NULL details: their release date is read from the year.
+		statements: []string{
+			`ALTER TABLE media ADD COLUMN release_date TEXT`,
+			`ALTER TABLE media ADD COLUMN runtime INTEGER`,
+			`ALTER TABLE media ADD COLUMN vote_average REAL`,
+			`ALTER TABLE media ADD COLUMN tagline TEXT`,
+			`ALTER TABLE media ADD COLUMN original_title TEXT`,
+			`ALTER TABLE media ADD COLUMN original_language TEXT`,
+			`ALTER TABLE media ADD COLUMN imdb_id TEXT`,
+			`CREATE TABLE genres (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+)`, `CREATE TABLE media_genres (
+	media_id INTEGER NOT NULL,
+	genre_id INTEGER NOT NULL REFERENCES genres(id),
+	PRIMARY KEY (media_id, genre_id)
+)`},
+	},
+	{
+		description: "create full-text search index of media",
+		// The index rowid is the media id. INSERT OR REPLACE doesn't fire delete triggers: the insert trigger removes the entry it replaces.
+		// Tables made by hand may lack the unique id, a single row per id is indexed.
+		statements: []string{
+			`CREATE VIRTUAL TABLE media_fts USING fts5(title, original_title, overview, director, tokenize='unicode61 remove_diacritics 2')`,
+			`CREATE TRIGGER media_fts_insert AFTER INSERT ON media BEGIN
+	DELETE FROM media_fts WHERE rowid=new.id;
+	INSERT INTO media_fts(rowid, title, original_title, overview, director) VALUES(new.id, new.title, new.original_title, new.overview, new.director);
+END`,
+			`CREATE TRIGGER media_fts_update AFTER UPDATE ON media BEGIN
+	DELETE FROM media_fts WHERE rowid=old.id;
+	INSERT INTO media_fts(rowid, title, original_title, overview, director) VALUES(new.id, new.title, new.original_title, new.overview, new.director);
+END`,
+			`CREATE TRIGGER media_fts_delete AFTER DELETE ON media BEGIN
+	DELETE FROM media_fts WHERE rowid=old.id;
+END`,
+			`INSERT INTO media_fts(rowid, title, original_title, overview, director) SELECT id, title, original_title, overview, director FROM media
+	WHERE rowid IN (SELECT max(rowid) FROM media WHERE id IS NOT NULL GROUP BY id)`,
+		},
+	},
+	{
+		description: "add original overview of media",
+		// The overview column is in the language of the api client.
+		statements: []string{`ALTER TABLE media ADD COLUMN original_overview TEXT`},
+	},
+	{
+		description: "create artwork table",
+		// The poster stays in the media table, artwork holds the other images of a media and the thumbnails of its poster.
+		statements: []string{`CREATE TABLE artwork (
+	media_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	size TEXT NOT NULL,
+	mime TEXT NOT NULL,
+	bytes BLOB NOT NULL,
+	sha256 TEXT NOT NULL,
+	PRIMARY KEY (media_id, kind, size)
+)`},
+	},
+}
+
+// SchemaVersion returns the version the schema of the db is at.
+func (dbh *DBHandler) SchemaVersion() (int, error) {
+	var version int
+	err := dbh.DB.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// migrate applies the migrations the db is missing, each in its own transaction.
+func (dbh *DBHandler) migrate() error {
+	version, err := dbh.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %v is newer than the latest known (%v), update mymedia", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		if err := dbh.applyMigration(i+1, migrations[i]); err != nil {
+			return fmt.Errorf("migration to version %v (%v): %w", i+1, migrations[i].description, err)
+		}
+	}
+	return nil
+}
+
+func (dbh *DBHandler) applyMigration(version int, m migration) error {
+	tx, err := dbh.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	// PRAGMA doesn't take bound parameters.
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MediaFilter selects media records, its zero fields select everything.
+type MediaFilter struct {
+	MediaType string
+	// MinYear and MaxYear bound the year, inclusively. 0 means no bound.
+	MinYear, MaxYear int
+	// Title, Director and Genre match case-insensitively.
+	// Title and Director match any part of the name, Genre the whole genre name.
+	Title    string
+	Director string
+	Genre    string
+	// MissingPoster selects the media without poster.
+	MissingPoster bool
+	// Sort is one of SortFields, the default is title.
+	Sort       string
+	Descending bool
+	// Limit is the maximum number of records, 0 means no limit.
+	Limit int
+}
+
+// sortColumns maps SortFields to their ORDER BY expression.
+var sortColumns = map[string]string{
+	"title":        "media.title COLLATE NOCASE",
+	"year":         "media.year",
+	"release_date": "COALESCE(media.release_date, printf('%04d-01-01', media.year))",
+	"runtime":      "media.runtime",
+	"vote_average": "media.vote_average",
+	"director":     "media.director COLLATE NOCASE",
+	"path":         "media.path",
+	"id":           "media.id",
+}
+
+// SortFields lists the fields ListMedia can sort by.
+var SortFields = []string{"title", "year", "release_date", "runtime", "vote_average", "director", "path", "id"}
+
+// ListMedia lists the media records selected by f. Their posters aren't read, see Record.HasPoster.
+func (dbh *DBHandler) ListMedia(f MediaFilter) ([]Record, error) {
+	var where []string
+	var args []any
+	if f.MediaType != "" {
+		where, args = append(where, "media.media_type=?"), append(args, f.MediaType)
+	}
+	if f.MinYear != 0 {
+		where, args = append(where, "media.year>=?"), append(args, f.MinYear)
+	}
+	if f.MaxYear != 0 {
+		where, args = append(where, "media.year<=?"), append(args, f.MaxYear)
+	}
+	if f.Title != "" {
+		where, args = append(where, `(media.title LIKE ? ESCAPE '\' OR media.original_title LIKE ? ESCAPE '\')`), append(args, likeAny(f.Title), likeAny(f.Title))
+	}
+	if f.Director != "" {
+		// Media scanned before credits were stored only have media.director.
+		where = append(where, `(media.director LIKE ? ESCAPE '\' OR EXISTS (SELECT 1 FROM credits JOIN people ON people.id=credits.person_id
+	WHERE credits.media_id=media.id AND credits.role='director' AND people.name LIKE ? ESCAPE '\'))`)
+		args = append(args, likeAny(f.Director), likeAny(f.Director))
+	}
+	if f.Genre != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM media_genres JOIN genres ON genres.id=media_genres.genre_id
+	WHERE media_genres.media_id=media.id AND lower(genres.name)=lower(?))`)
+		args = append(args, f.Genre)
+	}
+	if f.MissingPoster {
+		where = append(where, "(media.poster IS NULL OR length(media.poster)=0)")
+	}
+
+	sort := f.Sort
+	if sort == "" {
+		sort = "title"
+	}
+	column, ok := sortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("can't sort by %v, must be one of %v", sort, strings.Join(SortFields, ", "))
+	}
+	order := "ASC"
+	if f.Descending {
+		order = "DESC"
+	}
+
+	query := "SELECT " + recordColumns + ", media.poster IS NOT NULL AND length(media.poster)>0 FROM media"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %v %v, media.year %v, media.id", column, order, order)
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	return dbh.queryRecords(query, args...)
+}
+
+// queryRecords runs query, which selects recordColumns followed by whether the media has a poster, and reads its records with their genres.
+func (dbh *DBHandler) queryRecords(query string, args ...any) ([]Record, error) {
+	rows, err := dbh.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for rows.Next() {
+		var hasPoster bool
+		record, err := scanRecord(rows, &hasPoster)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		record.HasPoster = hasPoster
+		records = append(records, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Read once the rows are closed: a db with a single connection can't run both queries at once.
+	for i := range records {
+		if records[i].Genres, err = dbh.genres(records[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// likeAny returns a LIKE pattern with ESCAPE '\' matching the strings containing s.
+func likeAny(s string) string {
+	return "%" + escapeLike(s) + "%"
+}
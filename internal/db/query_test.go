@@ -0,0 +1,76 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestListMedia(t *testing.T) {
+	dbh := openTestDB(t)
+	drama := api.Genre{ID: 18, Name: "Drama"}
+	media := []api.Media{
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25", Director: "Ridley Scott", Runtime: 117, PosterData: []byte{1},
+			Genres: []api.Genre{{ID: 27, Name: "Horror"}, {ID: 878, Name: "Science Fiction"}}},
+		{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15", Director: "Michael Mann", Runtime: 170, PosterData: []byte{1},
+			Genres: []api.Genre{{ID: 80, Name: "Crime"}, drama}},
+		{ID: 345, MediaType: api.MediaTypeMovie, Title: "Eyes Wide Shut", ReleaseDate: "1999-07-16", Runtime: 159, Genres: []api.Genre{drama}},
+		{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14", Genres: []api.Genre{drama}},
+	}
+	for _, m := range media {
+		if _, err := dbh.WriteToDB(m, "/media/"+m.GetTitle()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only credits name the director.
+	if err := dbh.WriteCredits(345, []api.Credit{{PersonID: 240, Name: "Stanley Kubrick", Role: api.RoleDirector, Job: "Director"}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filter MediaFilter
+		want   []string
+	}{
+		{MediaFilter{}, []string{"Alien", "Eyes Wide Shut", "Heat", "The Expanse"}},
+		{MediaFilter{MediaType: api.MediaTypeMovie}, []string{"Alien", "Eyes Wide Shut", "Heat"}},
+		{MediaFilter{MinYear: 1990, MaxYear: 1999}, []string{"Eyes Wide Shut", "Heat"}},
+		{MediaFilter{MinYear: 1996}, []string{"Eyes Wide Shut", "The Expanse"}},
+		{MediaFilter{MaxYear: 1995}, []string{"Alien", "Heat"}},
+		{MediaFilter{Title: "EXP"}, []string{"The Expanse"}},
+		{MediaFilter{Director: "kubrick"}, []string{"Eyes Wide Shut"}},
+		{MediaFilter{Director: "Mann"}, []string{"Heat"}},
+		{MediaFilter{Genre: "drama"}, []string{"Eyes Wide Shut", "Heat", "The Expanse"}},
+		{MediaFilter{Genre: "dram"}, nil},
+		{MediaFilter{MissingPoster: true}, []string{"Eyes Wide Shut", "The Expanse"}},
+		{MediaFilter{Genre: "Drama", MediaType: api.MediaTypeMovie, MinYear: 1999}, []string{"Eyes Wide Shut"}},
+		{MediaFilter{Sort: "year", Descending: true}, []string{"The Expanse", "Eyes Wide Shut", "Heat", "Alien"}},
+		{MediaFilter{Sort: "runtime", Descending: true, Limit: 2}, []string{"Heat", "Eyes Wide Shut"}},
+		{MediaFilter{Title: "%"}, nil},
+	}
+	for _, test := range tests {
+		records, err := dbh.ListMedia(test.filter)
+		if err != nil {
+			t.Fatalf("ListMedia(%+v): %v", test.filter, err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.GetTitle())
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("ListMedia(%+v) = %q, want %q", test.filter, got, test.want)
+		}
+	}
+
+	records, err := dbh.ListMedia(MediaFilter{Title: "Heat"})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("ListMedia(Heat) = %v, %v", records, err)
+	}
+	heat := records[0]
+	if !heat.HasPoster || heat.PosterData != nil || heat.Path != "/media/Heat" || heat.GetReleaseDate() != "1995-12-15" ||
+		!slices.Equal(heat.GenreNames(), []string{"Crime", "Drama"}) {
+		t.Errorf("listed %+v", heat)
+	}
+	if _, err := dbh.ListMedia(MediaFilter{Sort: "poster"}); err == nil {
+		t.Error("sorting by an unknown field: got no error")
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PathRewrite is a path of the db moved by Relocate.
+type PathRewrite struct {
+	// Table is media, episodes or review_queue.
+	Table    string
+	Old, New string
+}
+
+// relocatedTables are the tables whose path column Relocate rewrites.
+var relocatedTables = []string{"media", "episodes", "review_queue"}
+
+// relocatePath returns path with its prefix from replaced by to, if path is from or in it.
+// from and to must be clean.
+func relocatePath(path string, from string, to string) (string, bool) {
+	if path == from {
+		return to, true
+	}
+	prefix := from
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(to, rest), true
+}
+
+// Relocate moves the paths of the db in from, or equal to it, to the same place in to, in a single transaction.
+// keep tells whether to do a rewrite, the others are returned in skipped.
+// With dryRun, nothing is written: done holds the rewrites that would be.
+func (dbh *DBHandler) Relocate(from string, to string, keep func(PathRewrite) bool, dryRun bool) (done []PathRewrite, skipped []PathRewrite, err error) {
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	tx, err := dbh.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	for _, table := range relocatedTables {
+		rows, err := tx.Query("SELECT DISTINCT path FROM " + table + " WHERE path IS NOT NULL ORDER BY path")
+		if err != nil {
+			return nil, nil, err
+		}
+		var rewrites []PathRewrite
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			if newPath, ok := relocatePath(path, from, to); ok {
+				rewrites = append(rewrites, PathRewrite{Table: table, Old: path, New: newPath})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rewrites {
+			if !keep(r) {
+				skipped = append(skipped, r)
+				continue
+			}
+			done = append(done, r)
+			if dryRun {
+				continue
+			}
+			// The path is the key of the review queue: an item already at the new path is replaced.
+			if _, err := tx.Exec("UPDATE OR REPLACE "+table+" SET path=? WHERE path=?", r.New, r.Old); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if dryRun {
+		return done, skipped, nil
+	}
+	return done, skipped, tx.Commit()
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestRelocatePath(t *testing.T) {
+	tests := []struct {
+		path, from, to string
+		want           string
+		ok             bool
+	}{
+		{"/mnt/old/Alien (1979)", "/mnt/old", "/srv/media", "/srv/media/Alien (1979)", true},
+		{"/mnt/old", "/mnt/old", "/srv/media", "/srv/media", true},
+		{"/mnt/old/A/Alien (1979)/S01E01.mkv", "/mnt/old/A", "/srv", "/srv/Alien (1979)/S01E01.mkv", true},
+		{"/mnt/older/Alien (1979)", "/mnt/old", "/srv/media", "", false},
+		{"/mnt/Alien (1979)", "/mnt/old", "/srv/media", "", false},
+		{"/mnt/old/Alien (1979)", "/", "/srv", "/srv/mnt/old/Alien (1979)", true},
+	}
+	for _, tt := range tests {
+		got, ok := relocatePath(tt.path, tt.from, tt.to)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relocatePath(%v, %v, %v) = %v, %v, want %v, %v", tt.path, tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	dbh := openTestDB(t)
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25"}
+	heat := api.Media{ID: 949, MediaType: api.MediaTypeMovie, Title: "Heat", ReleaseDate: "1995-12-15"}
+	show := api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", FirstAirDate: "2015-12-14"}
+	for path, m := range map[string]api.Media{
+		"/mnt/old/Alien (1979)":  alien,
+		"/mnt/old/Heat (1995)":   heat,
+		"/mnt/other/The Expanse": show,
+	} {
+		if _, err := dbh.WriteToDB(m, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dbh.WriteEpisode(show.ID, api.Episode{SeasonNumber: 1, EpisodeNumber: 1}, "/mnt/old/The Expanse/S01E01.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbh.QueueForReview("/mnt/old/Heat (1986)", "Heat", 1986, heat, "test"); err != nil {
+		t.Fatal(err)
+	}
+	keep := func(r PathRewrite) bool { return r.Old != "/mnt/old/Heat (1995)" }
+
+	done, skipped, err := dbh.Relocate("/mnt/old/", "/srv/media", keep, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 3 || len(skipped) != 1 || skipped[0].New != "/srv/media/Heat (1995)" {
+		t.Errorf("dry run got %+v and skipped %+v, want 3 rewrites and Heat skipped", done, skipped)
+	}
+	if record, found, err := dbh.FindMedia("Alien", 1979, 0); err != nil || !found || record.Path != "/mnt/old/Alien (1979)" {
+		t.Errorf("dry run wrote %+v (error %v)", record.Path, err)
+	}
+
+	if _, _, err := dbh.Relocate("/mnt/old/", "/srv/media", keep, false); err != nil {
+		t.Fatal(err)
+	}
+	records, err := dbh.ListMedia(MediaFilter{Sort: "path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range records {
+		paths = append(paths, r.Path)
+	}
+	want := []string{"/mnt/old/Heat (1995)", "/mnt/other/The Expanse", "/srv/media/Alien (1979)"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+	if episodes, err := dbh.Episodes(show.ID); err != nil || len(episodes) != 1 || episodes[0].Path != "/srv/media/The Expanse/S01E01.mkv" {
+		t.Errorf("got episodes %+v (error %v), want S01E01 moved", episodes, err)
+	}
+	if queue, err := dbh.ReviewQueue(); err != nil || len(queue) != 1 || queue[0].Path != "/srv/media/Heat (1986)" {
+		t.Errorf("got review queue %+v (error %v), want Heat moved", queue, err)
+	}
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// articles may lead a title, see FindMediaFuzzy.
+var articles = map[string]bool{
+	"a": true, "an": true, "the": true,
+	"l": true, "le": true, "la": true, "les": true, "un": true, "une": true,
+	"der": true, "die": true, "das": true,
+	"el": true, "los": true, "las": true, "il": true, "lo": true, "gli": true,
+}
+
+// words splits s into lowercase words, the way the full-text index tokenizes, but keeping accents.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// titleWords returns the words of title without its leading article, if it has one.
+func titleWords(title string) []string {
+	w := words(title)
+	if len(w) > 0 && articles[w[0]] {
+		return w[1:]
+	}
+	return w
+}
+
+// matchQuery returns a FTS5 query matching all of words, the last one as a prefix if prefix is true.
+// Words are quoted, so that the terms of users can't be read as FTS5 syntax.
+func matchQuery(words []string, prefix bool) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = `"` + w + `"`
+	}
+	if prefix && len(quoted) > 0 {
+		quoted[len(quoted)-1] += "*"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// SearchMedia lists the media whose title, original title, overview or director have all the words of terms, best match first.
+// Case and accents are ignored, the last word matches as a prefix.
+// A title equal to terms comes first, then titles weigh more than overviews.
+// Their posters aren't read, see Record.HasPoster. limit 0 means no limit.
+func (dbh *DBHandler) SearchMedia(terms string, limit int) ([]Record, error) {
+	w := words(terms)
+	if len(w) == 0 {
+		return nil, nil
+	}
+	query := "SELECT " + recordColumns + `, media.poster IS NOT NULL AND length(media.poster)>0
+FROM media_fts JOIN media ON media.id=media_fts.rowid
+WHERE media_fts MATCH ? ORDER BY lower(media.title)=lower(?) DESC, bm25(media_fts, 10.0, 10.0, 1.0, 2.0), media.id`
+	args := []any{matchQuery(w, true), strings.TrimSpace(terms)}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return dbh.queryRecords(query, args...)
+}
+
+// FindMediaFuzzy looks up the db for a media record whose title or original title has the same words as title, with a year within tolerance of year.
+// Case, accents and punctuation are ignored, and so is a leading article if the words don't match as they are:
+// 'Amelie' finds 'Amélie', 'Matrix' finds 'The Matrix'.
+// A year of 0 matches any year.
+func (dbh *DBHandler) FindMediaFuzzy(title string, year int, tolerance int) (record Record, found bool, err error) {
+	// The index matches all the words, the same number of them in the title means no others.
+	query := "SELECT " + recordColumns + `, media.poster
+FROM media_fts JOIN media ON media.id=media_fts.rowid
+WHERE media_fts MATCH ? AND (?=0 OR ABS(media.year-?)<=?) ORDER BY bm25(media_fts), media.id`
+search:
+	// Articles may be missing from the title in the db, or from title.
+	for _, split := range []func(string) []string{words, titleWords} {
+		needed := split(title)
+		if len(needed) == 0 {
+			continue
+		}
+		for _, column := range []string{"title", "original_title"} {
+			if record, found, err = dbh.findFuzzyIn(query, column, split, needed, year, tolerance); err != nil || found {
+				break search
+			}
+		}
+	}
+	if err != nil || !found {
+		return record, false, err
+	}
+	if record.Genres, err = dbh.genres(record.ID); err != nil {
+		return record, false, err
+	}
+	return record, true, nil
+}
+
+// findFuzzyIn runs the query of FindMediaFuzzy on column, and returns the first record with as many words as needed in column, split by split.
+// The words are grouped, a column filter only applies to the phrase right after it.
+func (dbh *DBHandler) findFuzzyIn(query string, column string, split func(string) []string, needed []string, year int, tolerance int) (Record, bool, error) {
+	rows, err := dbh.DB.Query(query, column+": ("+matchQuery(needed, false)+")", year, year, tolerance)
+	if err != nil {
+		return Record{}, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var poster []byte
+		record, err := scanRecord(rows, &poster)
+		if err != nil {
+			return record, false, err
+		}
+		value := record.GetTitle()
+		if column == "original_title" {
+			value = record.GetOriginalTitle()
+		}
+		if len(split(value)) == len(needed) {
+			record.PosterData, record.HasPoster = poster, len(poster) > 0
+			return record, true, nil
+		}
+	}
+	return Record{}, false, rows.Err()
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// writeSearchTestMedia writes media to search for.
+func writeSearchTestMedia(t *testing.T, dbh *DBHandler) {
+	t.Helper()
+	media := []api.Media{
+		{ID: 194, MediaType: api.MediaTypeMovie, Title: "Amélie", OriginalTitle: "Le Fabuleux Destin d'Amélie Poulain", ReleaseDate: "2001-04-25",
+			Overview: "A young waitress in Montmartre decides to change the lives of those around her.", Director: "Jean-Pierre Jeunet"},
+		{ID: 603, MediaType: api.MediaTypeMovie, Title: "The Matrix", ReleaseDate: "1999-03-31",
+			Overview: "A hacker learns about the true nature of reality.", Director: "Lana Wachowski"},
+		{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", ReleaseDate: "1979-05-25",
+			Overview: "The crew of the Nostromo meets an alien.", Director: "Ridley Scott"},
+		{ID: 679, MediaType: api.MediaTypeMovie, Title: "Aliens", ReleaseDate: "1986-07-18",
+			Overview: "Ripley goes back to the planet.", Director: "James Cameron"},
+	}
+	for _, m := range media {
+		if _, err := dbh.WriteToDB(m, "/films/"+m.GetTitle()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Writing again replaces the index entry.
+	if _, err := dbh.WriteToDB(media[2], "/films/Alien (1979)"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchMedia(t *testing.T) {
+	dbh := openTestDB(t)
+	writeSearchTestMedia(t, dbh)
+	tests := []struct {
+		terms string
+		want  []string
+	}{
+		{"amelie", []string{"Amélie"}},
+		{"fabuleux destin", []string{"Amélie"}},
+		{"montmartre", []string{"Amélie"}},
+		{"jeunet", []string{"Amélie"}},
+		{"matr", []string{"The Matrix"}},
+		// Titles first.
+		{"alien", []string{"Alien", "Aliens"}},
+		{"ridley ALIEN", []string{"Alien"}},
+		{`"NOT (`, nil},
+		{"", nil},
+		{"nothing", nil},
+	}
+	for _, test := range tests {
+		records, err := dbh.SearchMedia(test.terms, 0)
+		if err != nil {
+			t.Fatalf("SearchMedia(%q): %v", test.terms, err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.GetTitle())
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("SearchMedia(%q) = %q, want %q", test.terms, got, test.want)
+		}
+	}
+	if records, err := dbh.SearchMedia("alien", 1); err != nil || len(records) != 1 {
+		t.Errorf("SearchMedia with limit 1 = %v, %v", records, err)
+	}
+	if _, err := dbh.DB.Exec("DELETE FROM media WHERE id=348"); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := dbh.SearchMedia("nostromo", 0); err != nil || len(records) != 0 {
+		t.Errorf("SearchMedia of a deleted media = %v, %v", records, err)
+	}
+}
+
+func TestFindMediaFuzzy(t *testing.T) {
+	dbh := openTestDB(t)
+	writeSearchTestMedia(t, dbh)
+	tests := []struct {
+		title string
+		year  int
+		want  int
+	}{
+		{"Amelie", 2001, 194},
+		{"amélie", 0, 194},
+		{"Le fabuleux destin d'Amelie Poulain", 2001, 194},
+		{"Matrix", 1999, 603},
+		{"Matrix, The", 1999, 603},
+		{"Alien", 1979, 348},
+		{"Alien", 1986, 0},
+		{"Destin", 2001, 0},
+		{"Amelie", 1990, 0},
+		{"The", 1999, 0},
+	}
+	for _, test := range tests {
+		record, found, err := dbh.FindMediaFuzzy(test.title, test.year, 1)
+		if err != nil {
+			t.Fatalf("FindMediaFuzzy(%q, %v): %v", test.title, test.year, err)
+		}
+		if got := record.ID; !found && test.want != 0 || found && got != test.want {
+			t.Errorf("FindMediaFuzzy(%q, %v) = %v, %v, want %v", test.title, test.year, got, found, test.want)
+		}
+	}
+	// All the words must be in the title, not only the first one.
+	avp := api.Media{ID: 395, MediaType: api.MediaTypeMovie, Title: "Alien Versus", ReleaseDate: "2004-08-12",
+		Overview: "A covenant of hunters returns to Earth."}
+	if _, err := dbh.WriteToDB(avp, "/films/AVP"); err != nil {
+		t.Fatal(err)
+	}
+	if record, found, err := dbh.FindMediaFuzzy("Alien Covenant", 2004, 2); err != nil || found {
+		t.Errorf("FindMediaFuzzy(Alien Covenant) = %v, %v, %v, want nothing", record.GetTitle(), found, err)
+	}
+	if record, found, err := dbh.FindMedia("Alien: Covenant", 2004, 2); err != nil || found {
+		t.Errorf("FindMedia(Alien: Covenant) = %v, %v, %v, want nothing", record.GetTitle(), found, err)
+	}
+	// Only a leading article is ignored, and words that look like one match first.
+	for _, m := range []api.Media{
+		{ID: 313369, MediaType: api.MediaTypeMovie, Title: "La La Land", ReleaseDate: "2016-11-29"},
+		{ID: 1, MediaType: api.MediaTypeMovie, Title: "Land", ReleaseDate: "2016-01-01"},
+		{ID: 562, MediaType: api.MediaTypeMovie, Title: "Die Hard", ReleaseDate: "1988-07-15"},
+		{ID: 2, MediaType: api.MediaTypeMovie, Title: "Hard", ReleaseDate: "1988-01-01"},
+	} {
+		if _, err := dbh.WriteToDB(m, "/films/"+m.Title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for title, want := range map[string]int{"La La Land": 313369, "Land": 1, "Die Hard": 562, "Hard": 2} {
+		if record, found, err := dbh.FindMediaFuzzy(title, 0, 0); err != nil || !found || record.ID != want {
+			t.Errorf("FindMediaFuzzy(%q) = %v, %v, %v, want %v", title, record.ID, found, err, want)
+		}
+	}
+	// CheckDB falls back on it.
+	if !dbh.CheckDB("Amelie", 2001, 0, false) {
+		t.Error("CheckDB(Amelie) found nothing, want Amélie")
+	}
+}
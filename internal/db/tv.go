@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// WriteSeason writes season of the TV show with id showID, without its episodes.
+func (dbh *DBHandler) WriteSeason(showID int, season api.Season) error {
+	return writeSeason(dbh.DB, showID, season)
+}
+
+func writeSeason(e execer, showID int, season api.Season) error {
+	dbInsert := "INSERT OR REPLACE INTO seasons(show_id, season_number, id, name, overview, air_date) VALUES(?,?,?,?,?,?)"
+	_, err := e.Exec(dbInsert, showID, season.SeasonNumber, season.ID, season.Name, season.Overview, season.AirDate)
+	return err
+}
+
+// WriteEpisode writes episode of the TV show with id showID, found in the file at path.
+// path is empty for episodes without a file.
+func (dbh *DBHandler) WriteEpisode(showID int, episode api.Episode, path string) error {
+	return writeEpisode(dbh.DB, showID, episode, path)
+}
+
+func writeEpisode(e execer, showID int, episode api.Episode, path string) error {
+	dbInsert := "INSERT OR REPLACE INTO episodes(show_id, season_number, episode_number, id, name, overview, air_date, still, path) VALUES(?,?,?,?,?,?,?,?,?)"
+	_, err := e.Exec(dbInsert, showID, episode.SeasonNumber, episode.EpisodeNumber, episode.ID, episode.Name, episode.Overview, episode.AirDate, episode.StillData,
+		sql.NullString{String: path, Valid: path != ""})
+	return err
+}
+
+// EpisodeRecord is a row of the episodes table.
+type EpisodeRecord struct {
+	api.Episode
+	// Path is empty if the episode has no file.
+	Path string
+}
+
+// Episodes lists the episodes of the TV show with id showID, in order.
+// Stills aren't read.
+func (dbh *DBHandler) Episodes(showID int) ([]EpisodeRecord, error) {
+	query := "SELECT season_number, episode_number, id, name, overview, air_date, path FROM episodes WHERE show_id=? ORDER BY season_number, episode_number"
+	rows, err := dbh.DB.Query(query, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var episodes []EpisodeRecord
+	for rows.Next() {
+		var e EpisodeRecord
+		var name, overview, airDate, path sql.NullString
+		if err := rows.Scan(&e.SeasonNumber, &e.EpisodeNumber, &e.ID, &name, &overview, &airDate, &path); err != nil {
+			return nil, err
+		}
+		e.Name, e.Overview, e.AirDate, e.Path = name.String, overview.String, airDate.String, path.String
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
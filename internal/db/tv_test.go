@@ -0,0 +1,47 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestEpisodes(t *testing.T) {
+	dbh := openTestDB(t)
+	if err := dbh.WriteSeason(63639, api.Season{ID: 69486, SeasonNumber: 1, Name: "Season 1"}); err != nil {
+		t.Fatal(err)
+	}
+	episodes := []struct {
+		episode api.Episode
+		path    string
+	}{
+		{api.Episode{SeasonNumber: 1, EpisodeNumber: 2, Name: "The Big Empty"}, ""},
+		{api.Episode{SeasonNumber: 1, EpisodeNumber: 1, Name: "Dulcinea", StillData: []byte{1}}, "/shows/The Expanse/S01E01.mkv"},
+		{api.Episode{SeasonNumber: 0, EpisodeNumber: 1, Name: "Special"}, "/shows/The Expanse/S00E01.mkv"},
+	}
+	for _, e := range episodes {
+		if err := dbh.WriteEpisode(63639, e.episode, e.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Writing again replaces.
+	if err := dbh.WriteEpisode(63639, api.Episode{SeasonNumber: 1, EpisodeNumber: 2, Name: "The Big Empty"}, "/shows/The Expanse/S01E02.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dbh.Episodes(63639)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/shows/The Expanse/S00E01.mkv", "/shows/The Expanse/S01E01.mkv", "/shows/The Expanse/S01E02.mkv"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v episodes, want %v", len(got), len(want))
+	}
+	for i, e := range got {
+		if e.Path != want[i] {
+			t.Errorf("episode %v is %v @ %v, want path %v", i, e.Episode, e.Path, want[i])
+		}
+	}
+	if others, err := dbh.Episodes(1); err != nil || len(others) != 0 {
+		t.Errorf("Episodes of another show = %v, %v, want none", others, err)
+	}
+}
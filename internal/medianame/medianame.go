@@ -0,0 +1,182 @@
+// Package medianame reads media info from file and folder names,
+// following the naming conventions of media libraries and releases, e.g.
+//
+//	Alien (1979)
+//	Alien [1979]
+//	Alien.1979.1080p.BluRay.x264.mkv
+//	Alien (1979) {tmdb-348}
+//	The Expanse S01
+package medianame
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrNoTitle is returned when nothing in a name reads as a title.
+var ErrNoTitle = errors.New("found no title in name")
+
+// Info is what a name tells about its media.
+// Fields a name doesn't give are left to their zero value,
+// so a season 0 (specials) reads as no season.
+type Info struct {
+	Title   string
+	Year    int
+	Season  int
+	Episode int
+	TMDBID  int
+	// TMDBType is the media type of TMDBID, "movie" or "tv", if the name tells.
+	TMDBType string
+	// IMDbID is formatted as on IMDb, e.g. tt0078748.
+	IMDbID string
+}
+
+// SidecarFile is the name of the file pinning a media folder to its TMDB.org or IMDb id, see ParseSidecar.
+const SidecarFile = ".tmdb"
+
+// videoExtensions are the file extensions stripped from names.
+var videoExtensions = []string{".avi", ".iso", ".m2ts", ".m4v", ".mkv", ".mov", ".mp4", ".mpeg", ".mpg", ".ts", ".webm", ".wmv"}
+
+var (
+	// tmdbIDPattern matches ids tagged like Plex and Jellyfin do: {tmdb-348}, [tmdbid=348]...
+	tmdbIDPattern = regexp.MustCompile(`(?i)[\[{(]?\btmdb(?:id)?[-=: ]?(\d+)\b[\]})]?`)
+	// imdbIDPattern matches tagged ids, {imdb-tt0078748}, and bare ones, tt0078748.
+	imdbIDPattern = regexp.MustCompile(`(?i)[\[{(]?\b(?:imdb(?:id)?[-=: ]?)?(tt\d{7,8})\b[\]})]?`)
+	yearPattern   = regexp.MustCompile(`[(\[]?\b((?:18|19|20)\d\d)\b[)\]]?`)
+	// seasonPatterns match S01, S01E02, S01 E02, Season 1 and 1x02.
+	seasonPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bS(\d{1,2}) ?(?:E(\d{1,3}))?\b`),
+		regexp.MustCompile(`(?i)\bSeason ?(\d{1,2})\b`),
+		regexp.MustCompile(`\b(\d{1,2})x(\d{2,3})\b`),
+	}
+	// releaseTagPattern matches the technical tags of releases, which end the title.
+	// Words that may be part of a title, like "extended" or "french", are left out.
+	releaseTagPattern = regexp.MustCompile(`(?i)\b(?:4320p|2160p|1080[pi]|720p|576p|480p|4k|uhd|hdr10|bluray|blu-ray|bdrip|brrip|bdremux|remux|web-?dl|webrip|hdtv|dvdrip|x26[45]|h\.?26[45]|hevc|xvid|divx)\b`)
+	spaces            = regexp.MustCompile(`\s+`)
+
+	sidecarTMDBPattern = regexp.MustCompile(`^(?:https?://(?:www\.)?themoviedb\.org/)?(?:(movie|tv)/)?(\d+)(?:-[^/]*)?/?$`)
+	sidecarIMDbPattern = regexp.MustCompile(`^(?:https?://(?:www\.)?imdb\.com/title/)?(tt\d{7,8})/?$`)
+)
+
+// IsValidYear reports whether year is after the invention of cinema and less than 10y in the future.
+func IsValidYear(year int) bool { return year > 1800 && year < time.Now().Year()+10 }
+
+// IsVideoFile reports whether name has the extension of a video file.
+func IsVideoFile(name string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// Parse reads name, a file or folder name without its parent path.
+// The title is what comes before the first of the year, the season or the release tags,
+// with dots and underscores read as spaces when the name has no space.
+// Returns ErrNoTitle, with the rest of the info, if the title is empty, e.g. for "Season 1".
+func Parse(name string) (Info, error) {
+	var info Info
+	if IsVideoFile(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if m := tmdbIDPattern.FindStringSubmatchIndex(name); m != nil {
+		info.TMDBID, _ = strconv.Atoi(name[m[2]:m[3]])
+		name = name[:m[0]] + " " + name[m[1]:]
+	}
+	if m := imdbIDPattern.FindStringSubmatchIndex(name); m != nil {
+		info.IMDbID = strings.ToLower(name[m[2]:m[3]])
+		name = name[:m[0]] + " " + name[m[1]:]
+	}
+	name = strings.ReplaceAll(name, "_", " ")
+	if !strings.Contains(strings.TrimSpace(name), " ") {
+		name = strings.ReplaceAll(name, ".", " ")
+	}
+
+	end := len(name)
+	if start, year := findYear(name); year != 0 {
+		info.Year = year
+		end = min(end, start)
+	}
+	for _, pattern := range seasonPatterns {
+		m := pattern.FindStringSubmatchIndex(name)
+		if m == nil {
+			continue
+		}
+		info.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		if len(m) > 4 && m[4] != -1 {
+			info.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+		}
+		end = min(end, m[0])
+		break
+	}
+	if m := releaseTagPattern.FindStringIndex(name); m != nil {
+		end = min(end, m[0])
+	}
+
+	info.Title = strings.Trim(spaces.ReplaceAllString(name[:end], " "), " -.([{")
+	if info.Title == "" {
+		return info, ErrNoTitle
+	}
+	return info, nil
+}
+
+// findYear finds the year of name and where it starts.
+// A year in brackets wins over bare ones, then the last one does.
+// A bare year starting the name is part of the title, as in "1917 (2019)".
+func findYear(name string) (start int, year int) {
+	bracketed := false
+	for _, m := range yearPattern.FindAllStringSubmatchIndex(name, -1) {
+		isBracketed := m[0] != m[2]
+		if m[0] == 0 && !isBracketed {
+			continue
+		}
+		y, _ := strconv.Atoi(name[m[2]:m[3]])
+		if !IsValidYear(y) {
+			continue
+		}
+		if bracketed && !isBracketed {
+			continue
+		}
+		start, year, bracketed = m[0], y, isBracketed
+	}
+	return start, year
+}
+
+// ParseSidecar reads the ids in the content of a SidecarFile, from its first line. It may hold:
+//   - a TMDB id, optionally prefixed by its media type: 603, movie/603 or tv/1399,
+//   - a TMDB.org url: https://www.themoviedb.org/movie/603-the-matrix,
+//   - an IMDb id or url: tt0133093 or https://www.imdb.com/title/tt0133093/.
+func ParseSidecar(content string) (Info, error) {
+	var info Info
+	line, _, _ := strings.Cut(content, "\n")
+	line = strings.TrimSpace(line)
+	if m := sidecarTMDBPattern.FindStringSubmatch(line); m != nil {
+		info.TMDBType = m[1]
+		info.TMDBID, _ = strconv.Atoi(m[2])
+		return info, nil
+	}
+	if m := sidecarIMDbPattern.FindStringSubmatch(line); m != nil {
+		info.IMDbID = m[1]
+		return info, nil
+	}
+	return info, fmt.Errorf("%v doesn't hold a TMDB.org or IMDb id: %q", SidecarFile, line)
+}
+
+// sanitizer replaces the characters that some filesystems, or media servers, don't allow in names.
+var sanitizer = strings.NewReplacer(": ", " - ", ":", "-", "/", "-", "\\", "-", "<", "", ">", "", "\"", "", "|", "", "?", "", "*", "")
+
+// Sanitize makes s usable as a file or folder name on common filesystems:
+// separators and reserved characters are replaced or removed, as are control characters,
+// spaces are collapsed and trailing dots removed. "Face/Off" gives "Face-Off", "Mission: Impossible" gives "Mission - Impossible".
+func Sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, sanitizer.Replace(s))
+	return strings.TrimRight(strings.TrimSpace(spaces.ReplaceAllString(s, " ")), ". ")
+}
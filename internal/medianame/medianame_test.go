@@ -0,0 +1,165 @@
+package medianame
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Info
+	}{
+		// TITLE (YEAR)
+		{"Alien (1979)", Info{Title: "Alien", Year: 1979}},
+		{"Alien  (1979)", Info{Title: "Alien", Year: 1979}},
+		{"The Thin Red Line (1998)", Info{Title: "The Thin Red Line", Year: 1998}},
+		{"Metropolis (1927)", Info{Title: "Metropolis", Year: 1927}},
+		{"Alien (1979) - Director's Cut", Info{Title: "Alien", Year: 1979}},
+		{"Mr. Smith Goes to Washington (1939)", Info{Title: "Mr. Smith Goes to Washington", Year: 1939}},
+		{"E.T. the Extra-Terrestrial (1982)", Info{Title: "E.T. the Extra-Terrestrial", Year: 1982}},
+		{"Amélie (2001)", Info{Title: "Amélie", Year: 2001}},
+		{"Ocean's Eleven (2001)", Info{Title: "Ocean's Eleven", Year: 2001}},
+		{"Se7en (1995)", Info{Title: "Se7en", Year: 1995}},
+		{"S1m0ne (2002)", Info{Title: "S1m0ne", Year: 2002}},
+		// Years in titles.
+		{"1917 (2019)", Info{Title: "1917", Year: 2019}},
+		{"2001 A Space Odyssey (1968)", Info{Title: "2001 A Space Odyssey", Year: 1968}},
+		{"2001 A Space Odyssey", Info{Title: "2001 A Space Odyssey"}},
+		{"Blade Runner 2049 (2017)", Info{Title: "Blade Runner 2049", Year: 2017}},
+		{"Blade.Runner.2049.2017.1080p.BluRay.x264", Info{Title: "Blade Runner 2049", Year: 2017}},
+		{"Apollo 13 (1995)", Info{Title: "Apollo 13", Year: 1995}},
+		{"Fahrenheit 451 (1966)", Info{Title: "Fahrenheit 451", Year: 1966}},
+		// Other brackets, no brackets.
+		{"Alien [1979]", Info{Title: "Alien", Year: 1979}},
+		{"Alien 1979", Info{Title: "Alien", Year: 1979}},
+		{"Alien - 1979", Info{Title: "Alien", Year: 1979}},
+		// Release names.
+		{"Alien.1979.1080p.BluRay.x264-GROUP", Info{Title: "Alien", Year: 1979}},
+		{"The.Thin.Red.Line.1998.720p.WEB-DL.H264", Info{Title: "The Thin Red Line", Year: 1998}},
+		{"Heat_1995_2160p_UHD_BluRay_REMUX_HEVC", Info{Title: "Heat", Year: 1995}},
+		{"Heat 1995 1080p WEBRip x265", Info{Title: "Heat", Year: 1995}},
+		{"Alien.1080p.BluRay", Info{Title: "Alien"}},
+		{"Alien (1979) [1080p]", Info{Title: "Alien", Year: 1979}},
+		// Files.
+		{"Alien (1979).mkv", Info{Title: "Alien", Year: 1979}},
+		{"Alien.1979.1080p.BluRay.x264.MP4", Info{Title: "Alien", Year: 1979}},
+		{"Mr. Robot.avi", Info{Title: "Mr. Robot"}},
+		// Embedded ids.
+		{"Alien (1979) {tmdb-348}", Info{Title: "Alien", Year: 1979, TMDBID: 348}},
+		{"Alien (1979) [tmdbid-348]", Info{Title: "Alien", Year: 1979, TMDBID: 348}},
+		{"Alien (1979) {tmdbid=348}", Info{Title: "Alien", Year: 1979, TMDBID: 348}},
+		{"Alien (1979) {imdb-tt0078748}", Info{Title: "Alien", Year: 1979, IMDbID: "tt0078748"}},
+		{"Alien (1979) [imdbid-tt0078748]", Info{Title: "Alien", Year: 1979, IMDbID: "tt0078748"}},
+		{"Alien.1979.tt0078748.1080p", Info{Title: "Alien", Year: 1979, IMDbID: "tt0078748"}},
+		{"Alien (1979) {tmdb-348} {imdb-tt0078748}", Info{Title: "Alien", Year: 1979, TMDBID: 348, IMDbID: "tt0078748"}},
+		{"{tmdb-348} Alien (1979)", Info{Title: "Alien", Year: 1979, TMDBID: 348}},
+		// Seasons and episodes.
+		{"The Expanse S01", Info{Title: "The Expanse", Season: 1}},
+		{"The Expanse (2015) S02", Info{Title: "The Expanse", Year: 2015, Season: 2}},
+		{"The.Expanse.S03E04.1080p.WEB-DL", Info{Title: "The Expanse", Season: 3, Episode: 4}},
+		{"The Expanse S03 E04", Info{Title: "The Expanse", Season: 3, Episode: 4}},
+		{"The Expanse - Season 4", Info{Title: "The Expanse", Season: 4}},
+		{"The Expanse 5x10", Info{Title: "The Expanse", Season: 5, Episode: 10}},
+		{"The Expanse (2015) {tmdb-63639} S01E01.mkv", Info{Title: "The Expanse", Year: 2015, Season: 1, Episode: 1, TMDBID: 63639}},
+		// Out of range years are part of the title.
+		{"Metropolis (1700)", Info{Title: "Metropolis (1700)"}},
+		{"Space 2099", Info{Title: "Space 2099"}},
+		{"Alien (79)", Info{Title: "Alien (79)"}},
+		{"extras", Info{Title: "extras"}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.name)
+		if err != nil || got != test.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestParseNoTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		want Info
+	}{
+		{"", Info{}},
+		{"(1979)", Info{Year: 1979}},
+		{"Season 1", Info{Season: 1}},
+		{"S02E03.mkv", Info{Season: 2, Episode: 3}},
+		{"1080p.BluRay", Info{}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.name)
+		if !errors.Is(err, ErrNoTitle) || got != test.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v, %v", test.name, got, err, test.want, ErrNoTitle)
+		}
+	}
+}
+
+func TestIsValidYear(t *testing.T) {
+	tests := []struct {
+		year int
+		want bool
+	}{
+		{1800, false},
+		{1895, true},
+		{2024, true},
+		{9999, false},
+	}
+	for _, test := range tests {
+		if got := IsValidYear(test.year); got != test.want {
+			t.Errorf("IsValidYear(%v) = %v, want %v", test.year, got, test.want)
+		}
+	}
+}
+
+func TestParseSidecar(t *testing.T) {
+	tests := []struct {
+		content string
+		want    Info
+		wantErr bool
+	}{
+		{content: "603", want: Info{TMDBID: 603}},
+		{content: "movie/603\n", want: Info{TMDBID: 603, TMDBType: "movie"}},
+		{content: " tv/1399 \nsome notes", want: Info{TMDBID: 1399, TMDBType: "tv"}},
+		{content: "https://www.themoviedb.org/movie/603-the-matrix", want: Info{TMDBID: 603, TMDBType: "movie"}},
+		{content: "https://themoviedb.org/tv/1399", want: Info{TMDBID: 1399, TMDBType: "tv"}},
+		{content: "tt0133093", want: Info{IMDbID: "tt0133093"}},
+		{content: "https://www.imdb.com/title/tt0133093/", want: Info{IMDbID: "tt0133093"}},
+		{content: "", wantErr: true},
+		{content: "person/287", wantErr: true},
+		{content: "The Matrix", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSidecar(test.content)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseSidecar(%q) = %+v, want an error", test.content, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseSidecar(%q) = %+v, %v, want %+v", test.content, got, err, test.want)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Alien", "Alien"},
+		{"Face/Off", "Face-Off"},
+		{"Mission: Impossible", "Mission - Impossible"},
+		{"10:30 P.M. Summer", "10-30 P.M. Summer"},
+		{`What "Women" Want?`, "What Women Want"},
+		{"AC/DC: Let There Be Rock", "AC-DC - Let There Be Rock"},
+		{"Les Misérables...", "Les Misérables"},
+		{"  Tab\there  ", "Tab here"},
+	}
+	for _, test := range tests {
+		if got := Sanitize(test.name); got != test.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package nfo reads and writes the .nfo files Kodi and Jellyfin read the metadata of a media from,
+// see https://kodi.wiki/view/NFO_files.
+package nfo
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+// PosterFile is the name of the poster image Kodi and Jellyfin read in a media folder.
+const PosterFile = "poster.jpg"
+
+// artworkNames are the names, without extension, of the images Kodi and Jellyfin read in a media folder, by kind of artwork.
+var artworkNames = map[string]string{api.ArtworkBackdrop: "fanart", api.ArtworkLogo: "clearlogo"}
+
+// ArtworkFileName returns the name of the file of a in a media folder, e.g. fanart.jpg,
+// or an empty name if Kodi and Jellyfin don't read its kind or its format.
+func ArtworkFileName(a api.Artwork) string {
+	name, ext := artworkNames[a.Kind], api.ImageExt(a.Mime)
+	if name == "" || ext == "" {
+		return ""
+	}
+	return name + ext
+}
+
+// FileName returns the name of the .nfo file of a media of mediaType in its folder.
+func FileName(mediaType string) string {
+	if mediaType == api.MediaTypeTV {
+		return "tvshow.nfo"
+	}
+	return "movie.nfo"
+}
+
+// UniqueID is the id of a media on a site, e.g. tmdb or imdb.
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+type Rating struct {
+	Name    string  `xml:"name,attr"`
+	Max     int     `xml:"max,attr"`
+	Default bool    `xml:"default,attr,omitempty"`
+	Value   float64 `xml:"value"`
+}
+
+type Actor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role,omitempty"`
+	Order int    `xml:"order"`
+}
+
+// NFO is the root element of a movie.nfo or a tvshow.nfo, XMLName tells which.
+// Directors and Credits, the writers, are only written for movies.
+type NFO struct {
+	XMLName       xml.Name
+	Title         string     `xml:"title"`
+	OriginalTitle string     `xml:"originaltitle,omitempty"`
+	Year          int        `xml:"year,omitempty"`
+	Premiered     string     `xml:"premiered,omitempty"`
+	Plot          string     `xml:"plot,omitempty"`
+	Tagline       string     `xml:"tagline,omitempty"`
+	Runtime       int        `xml:"runtime,omitempty"`
+	Ratings       []Rating   `xml:"ratings>rating,omitempty"`
+	UniqueIDs     []UniqueID `xml:"uniqueid"`
+	Genres        []string   `xml:"genre"`
+	Directors     []string   `xml:"director"`
+	Credits       []string   `xml:"credits"`
+	Actors        []Actor    `xml:"actor"`
+	// TMDBID and IMDbID are written by Jellyfin, along with the uniqueid elements. Only read, see ID.
+	TMDBID string `xml:"tmdbid,omitempty"`
+	IMDbID string `xml:"imdbid,omitempty"`
+}
+
+// New returns the NFO of m, with its credits.
+func New(m api.Media) NFO {
+	root := "movie"
+	if m.MediaType == api.MediaTypeTV {
+		root = "tvshow"
+	}
+	n := NFO{
+		XMLName:   xml.Name{Local: root},
+		Title:     m.GetTitle(),
+		Premiered: m.GetReleaseDate(),
+		Plot:      m.Overview,
+		Tagline:   m.Tagline,
+		Runtime:   m.GetRuntime(),
+		Genres:    m.GenreNames(),
+	}
+	// Media found on OMDb have no TMDB id, see api.IMDbMediaID.
+	if m.ID > 0 {
+		n.UniqueIDs = []UniqueID{{Type: "tmdb", Default: true, Value: strconv.Itoa(m.ID)}}
+	}
+	if original := m.GetOriginalTitle(); original != n.Title {
+		n.OriginalTitle = original
+	}
+	if year := m.GetYear(); year > 0 {
+		n.Year = year
+	}
+	if m.VoteAverage > 0 {
+		n.Ratings = []Rating{{Name: "themoviedb", Max: 10, Default: true, Value: m.VoteAverage}}
+		if m.ID < 0 {
+			n.Ratings[0].Name = "imdb"
+		}
+	}
+	if m.IMDbID != "" {
+		n.UniqueIDs = append(n.UniqueIDs, UniqueID{Type: "imdb", Default: m.ID < 0, Value: m.IMDbID})
+	}
+	for _, c := range m.Credits {
+		switch {
+		case c.Role == api.RoleCast:
+			n.Actors = append(n.Actors, Actor{Name: c.Name, Role: c.Character, Order: c.Order})
+		case m.MediaType == api.MediaTypeTV:
+		case c.Role == api.RoleDirector && !slices.Contains(n.Directors, c.Name):
+			n.Directors = append(n.Directors, c.Name)
+		case c.Role == api.RoleWriter && !slices.Contains(n.Credits, c.Name):
+			n.Credits = append(n.Credits, c.Name)
+		}
+	}
+	// Credits may not be stored, the director is.
+	if len(n.Directors) == 0 && m.Director != "" && m.MediaType != api.MediaTypeTV {
+		n.Directors = []string{m.Director}
+	}
+	return n
+}
+
+// Write writes n to w as an indented XML document.
+func Write(w io.Writer, n NFO) error {
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(n); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// posterFiles are the names of the poster images read in a media folder, by preference.
+var posterFiles = []string{PosterFile, "poster.jpeg", "folder.jpg", "folder.jpeg", "cover.jpg"}
+
+// FindPoster returns the path of the poster image in dir, see posterFiles, or an empty path if there's none.
+func FindPoster(dir string) string {
+	for _, name := range posterFiles {
+		p := filepath.Join(dir, name)
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+// Find returns the path of the NFO file in dir: tvshow.nfo, movie.nfo, or else the only .nfo file, usually named after the video file.
+// The path is empty if there's none.
+func Find(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var others []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".nfo") {
+			continue
+		}
+		switch strings.ToLower(e.Name()) {
+		case "tvshow.nfo", "movie.nfo":
+			return filepath.Join(dir, e.Name()), nil
+		}
+		others = append(others, e.Name())
+	}
+	if len(others) == 1 {
+		return filepath.Join(dir, others[0]), nil
+	}
+	return "", nil
+}
+
+// Read decodes a movie or tvshow NFO document.
+func Read(r io.Reader) (NFO, error) {
+	var n NFO
+	if err := xml.NewDecoder(r).Decode(&n); err != nil {
+		return n, err
+	}
+	if n.MediaType() == "" {
+		return n, fmt.Errorf("unknown NFO root element <%v>, want <movie> or <tvshow>", n.XMLName.Local)
+	}
+	return n, nil
+}
+
+// ReadFile decodes the NFO file at path, see Read.
+func ReadFile(path string) (NFO, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return NFO{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// MediaType returns the media type of n, empty if it's neither a movie nor a TV show.
+func (n NFO) MediaType() string {
+	switch n.XMLName.Local {
+	case "movie":
+		return api.MediaTypeMovie
+	case "tvshow":
+		return api.MediaTypeTV
+	}
+	return ""
+}
+
+// ID returns the id of the media of n on site, e.g. tmdb or imdb, from its uniqueid elements,
+// or from the tmdbid and imdbid elements Jellyfin writes too.
+func (n NFO) ID(site string) string {
+	for _, id := range n.UniqueIDs {
+		if strings.EqualFold(id.Type, site) && strings.TrimSpace(id.Value) != "" {
+			return strings.TrimSpace(id.Value)
+		}
+	}
+	switch site {
+	case "tmdb":
+		return strings.TrimSpace(n.TMDBID)
+	case "imdb":
+		return strings.TrimSpace(n.IMDbID)
+	}
+	return ""
+}
+
+// Media returns the media n describes, without credits, since NFO files don't give the ids of people.
+// Its id is the TMDB.org id of n, 0 if n has none. Genres that aren't TMDB.org's are dropped.
+func (n NFO) Media() api.Media {
+	m := api.Media{MediaType: n.MediaType(), Overview: n.Plot, Tagline: n.Tagline, IMDbID: n.ID("imdb")}
+	m.ID, _ = strconv.Atoi(n.ID("tmdb"))
+	date := n.Premiered
+	if date == "" && n.Year > 0 {
+		date = fmt.Sprintf("%04d-01-01", n.Year)
+	}
+	m.SetTitleAndDate(n.Title, date)
+	// New leaves out an original title that's the title.
+	m.SetOriginalTitle(cmp.Or(n.OriginalTitle, n.Title))
+	if m.MediaType == api.MediaTypeTV {
+		if n.Runtime > 0 {
+			m.EpisodeRunTime = []int{n.Runtime}
+		}
+	} else {
+		m.Runtime = n.Runtime
+	}
+	for _, r := range n.Ratings {
+		if r.Default || (m.VoteAverage == 0 && r.Name == "themoviedb") {
+			m.VoteAverage = r.Value * 10 / float64(max(r.Max, 1))
+		}
+	}
+	for _, g := range n.Genres {
+		if id, ok := api.TMDBGenreIDs[g]; ok {
+			m.Genres = append(m.Genres, api.Genre{ID: id, Name: g})
+		}
+	}
+	if len(n.Directors) > 0 {
+		m.Director = n.Directors[0]
+	}
+	return m
+}
@@ -0,0 +1,164 @@
+package nfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JeanLeonHenry/mymedia/internal/api"
+)
+
+func TestWrite(t *testing.T) {
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", OriginalTitle: "Alien", ReleaseDate: "1979-05-25",
+		Overview: "In deep space, <the crew> of the commercial starship Nostromo...", Runtime: 117, VoteAverage: 8.1, IMDbID: "tt0078748",
+		Genres:   []api.Genre{{ID: 27, Name: "Horror"}, {ID: 878, Name: "Science Fiction"}},
+		Director: "Ridley Scott",
+		Credits: []api.Credit{
+			{PersonID: 10205, Name: "Sigourney Weaver", Role: api.RoleCast, Character: "Ellen Ripley", Order: 1},
+			{PersonID: 578, Name: "Ridley Scott", Role: api.RoleDirector, Job: "Director"},
+			{PersonID: 8935, Name: "Dan O'Bannon", Role: api.RoleWriter, Job: "Screenplay"},
+			{PersonID: 8935, Name: "Dan O'Bannon", Role: api.RoleWriter, Job: "Story"},
+		}}
+	var b strings.Builder
+	if err := Write(&b, New(alien)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<movie>
+  <title>Alien</title>
+  <year>1979</year>
+  <premiered>1979-05-25</premiered>
+  <plot>In deep space, &lt;the crew&gt; of the commercial starship Nostromo...</plot>
+  <runtime>117</runtime>
+  <ratings>
+    <rating name="themoviedb" max="10" default="true">
+      <value>8.1</value>
+    </rating>
+  </ratings>
+  <uniqueid type="tmdb" default="true">348</uniqueid>
+  <uniqueid type="imdb">tt0078748</uniqueid>
+  <genre>Horror</genre>
+  <genre>Science Fiction</genre>
+  <director>Ridley Scott</director>
+  <credits>Dan O&#39;Bannon</credits>
+  <actor>
+    <name>Sigourney Weaver</name>
+    <role>Ellen Ripley</role>
+    <order>1</order>
+  </actor>
+</movie>
+`
+	if b.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", b.String(), want)
+	}
+}
+
+func TestNewShow(t *testing.T) {
+	show := api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", OriginalName: "The Expanse", FirstAirDate: "2015-12-14",
+		EpisodeRunTime: []int{43}, Director: "Jeff Woolnough",
+		Credits: []api.Credit{{PersonID: 1, Name: "Jeff Woolnough", Role: api.RoleDirector, Job: "Director"}}}
+	n := New(show)
+	if n.XMLName.Local != "tvshow" || n.Title != "The Expanse" || n.Year != 2015 || n.Runtime != 43 || len(n.Directors) != 0 {
+		t.Errorf("got %+v, want a tvshow without director", n)
+	}
+	if got := FileName(show.MediaType); got != "tvshow.nfo" {
+		t.Errorf("FileName(tv) = %v, want tvshow.nfo", got)
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want api.Media
+	}{
+		{"kodi movie", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<movie>
+  <title>Alien</title>
+  <originaltitle>Alien</originaltitle>
+  <year>1979</year>
+  <premiered>1979-05-25</premiered>
+  <runtime>117</runtime>
+  <ratings><rating name="imdb" max="10"><value>8.5</value></rating><rating name="themoviedb" max="10" default="true"><value>8.1</value></rating></ratings>
+  <uniqueid type="imdb">tt0078748</uniqueid>
+  <uniqueid type="tmdb" default="true">348</uniqueid>
+  <genre>Horror</genre>
+  <genre>Science-Fiction</genre>
+  <director>Ridley Scott</director>
+</movie>`, api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", OriginalTitle: "Alien", ReleaseDate: "1979-05-25",
+			Runtime: 117, VoteAverage: 8.1, IMDbID: "tt0078748", Genres: []api.Genre{{ID: 27, Name: "Horror"}}, Director: "Ridley Scott"}},
+		{"jellyfin show", `<tvshow><title>The Expanse</title><year>2015</year><runtime>43</runtime><tmdbid>63639</tmdbid></tvshow>`,
+			api.Media{ID: 63639, MediaType: api.MediaTypeTV, Name: "The Expanse", OriginalName: "The Expanse", FirstAirDate: "2015-01-01", EpisodeRunTime: []int{43}}},
+	}
+	for _, tt := range tests {
+		n, err := Read(strings.NewReader(tt.doc))
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if got := n.Media(); got.Dump() != tt.want.Dump() {
+			t.Errorf("%v: got %v, want %v", tt.name, got.Dump(), tt.want.Dump())
+		}
+	}
+	if _, err := Read(strings.NewReader(`<episodedetails><title>Pilot</title></episodedetails>`)); err == nil {
+		t.Error("read an episode NFO, want an error")
+	}
+	if _, err := Read(strings.NewReader("https://www.themoviedb.org/movie/348")); err == nil {
+		t.Error("read an url, want an error")
+	}
+}
+
+func TestReadWritten(t *testing.T) {
+	alien := api.Media{ID: 348, MediaType: api.MediaTypeMovie, Title: "Alien", OriginalTitle: "Alien", ReleaseDate: "1979-05-25",
+		Runtime: 117, VoteAverage: 8.1, IMDbID: "tt0078748", Genres: []api.Genre{{ID: 27, Name: "Horror"}}, Director: "Ridley Scott"}
+	var b strings.Builder
+	if err := Write(&b, New(alien)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Read(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Media(); got.Dump() != alien.Dump() {
+		t.Errorf("got %v, want %v", got.Dump(), alien.Dump())
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		files []string
+		nfo   string
+	}{
+		{[]string{"Alien.mkv", "Alien.nfo", "movie.nfo"}, "movie.nfo"},
+		{[]string{"tvshow.nfo", "S01E01.nfo"}, "tvshow.nfo"},
+		{[]string{"Alien.mkv", "Alien.nfo"}, "Alien.nfo"},
+		{[]string{"CD1.nfo", "CD2.nfo"}, ""},
+		{[]string{"Alien.mkv"}, ""},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for _, name := range tt.files {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := Find(dir)
+		want := ""
+		if tt.nfo != "" {
+			want = filepath.Join(dir, tt.nfo)
+		}
+		if got != want || err != nil {
+			t.Errorf("Find(%v) = %q, %v, want %q", tt.files, got, err, want)
+		}
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"folder.jpg", "poster.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := FindPoster(dir); got != filepath.Join(dir, PosterFile) {
+		t.Errorf("FindPoster() = %q, want poster.jpg", got)
+	}
+}
@@ -0,0 +1,68 @@
+// Package thumbnail makes small JPEG copies of posters, for previews in the terminal.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// Mime is the mime type of thumbnails.
+const Mime = "image/jpeg"
+
+// Quality is the JPEG quality of thumbnails.
+const Quality = 85
+
+// Make decodes the JPEG or PNG image in data, and returns a JPEG copy of it scaled down to width, keeping its aspect ratio.
+// Images already narrower than width keep their size.
+func Make(data []byte, width int) ([]byte, error) {
+	if width <= 0 {
+		return nil, errors.New("thumbnail width must be positive")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, scale(src, width), &jpeg.Options{Quality: Quality}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// scale scales img down to width by averaging the pixels each pixel of the result covers.
+func scale(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= width {
+		return img
+	}
+	height := max(1, srcH*width/srcW)
+	src := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,58 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 60; y++ {
+			// Left half black, right half white.
+			img.Set(x, y, color.Gray{Y: uint8(255 * (x / 20))})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		width                 int
+		wantWidth, wantHeight int
+	}{
+		{10, 10, 15},
+		{7, 7, 10},
+		// Not scaled up.
+		{100, 40, 60},
+	}
+	for _, test := range tests {
+		data, err := Make(encoded.Bytes(), test.width)
+		if err != nil {
+			t.Fatalf("Make(%v): %v", test.width, err)
+		}
+		thumb, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Make(%v) isn't a JPEG: %v", test.width, err)
+		}
+		if b := thumb.Bounds(); b.Dx() != test.wantWidth || b.Dy() != test.wantHeight {
+			t.Errorf("Make(%v) is %vx%v, want %vx%v", test.width, b.Dx(), b.Dy(), test.wantWidth, test.wantHeight)
+		}
+		// JPEG is lossy, colors are only close.
+		left, _, _, _ := thumb.At(0, 0).RGBA()
+		right, _, _, _ := thumb.At(thumb.Bounds().Dx()-1, 0).RGBA()
+		if left > 0x2000 || right < 0xe000 {
+			t.Errorf("Make(%v) has left and right colors %#x and %#x, want black and white", test.width, left, right)
+		}
+	}
+	if _, err := Make([]byte("not an image"), 10); err == nil {
+		t.Error("Make(garbage): got no error")
+	}
+	if _, err := Make(encoded.Bytes(), 0); err == nil {
+		t.Error("Make(width 0): got no error")
+	}
+}
@@ -12,10 +12,18 @@ var Abs = func(x int) int {
 	return x
 }
 
-func AcceptOrQuit(prompt string) {
+// Accept asks prompt on stdin and reports whether the user answered "y".
+func Accept(prompt string) bool {
 	fmt.Print(prompt + " [y/N] ")
 	var userInput string
 	if _, err := fmt.Scanln(&userInput); err != nil || userInput != "y" {
+		return false
+	}
+	return true
+}
+
+func AcceptOrQuit(prompt string) {
+	if !Accept(prompt) {
 		fmt.Println("Quitting.")
 		os.Exit(1)
 	}